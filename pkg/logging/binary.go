@@ -0,0 +1,271 @@
+package logging
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Binary formats (MsgpackFormatter and CBORFormatter) write each entry as a
+// map with these keys:
+//
+//	time        timestamp (MessagePack timestamp extension, CBOR tag 1)
+//	level       level value as an integer
+//	level_name  level name as a string
+//	message     message as a string
+//	caller      "file:line", omitted when empty
+//	fields      map of field names to values, omitted when empty
+//
+// Field values are written as nil, booleans, integers, floats, strings,
+// byte strings, timestamps, arrays and maps. Errors and fmt.Stringer values
+// are written as strings, durations as integer nanoseconds, stack traces as
+// arrays of {function, file, line} maps, and other types as their JSON
+// representation.
+const (
+	binaryKeyTime      = "time"
+	binaryKeyLevel     = "level"
+	binaryKeyLevelName = "level_name"
+	binaryKeyMessage   = "message"
+	binaryKeyCaller    = "caller"
+	binaryKeyFields    = "fields"
+)
+
+// binaryMaxLength limits the length of strings, byte strings, arrays and
+// maps accepted by the decoders
+const binaryMaxLength = 64 << 20
+
+// binaryMaxDepth limits the nesting of arrays and maps
+const binaryMaxDepth = 64
+
+// errBinaryTooDeep is returned for values nested deeper than binaryMaxDepth
+var errBinaryTooDeep = errors.New("value nested too deeply")
+
+// binaryEntryMap returns the schema map for entry with normalized values
+func binaryEntryMap(entry *Entry) map[string]interface{} {
+	m := map[string]interface{}{
+		binaryKeyTime:      entry.Time,
+		binaryKeyLevel:     int64(entry.Level.Value),
+		binaryKeyLevelName: entry.Level.Name,
+		binaryKeyMessage:   entry.Message,
+	}
+	if entry.Caller != "" {
+		m[binaryKeyCaller] = entry.Caller
+	}
+	if len(entry.Fields) > 0 {
+		fields := make(map[string]interface{}, len(entry.Fields))
+		for k, v := range entry.Fields {
+			fields[k] = binaryValue(v)
+		}
+		m[binaryKeyFields] = fields
+	}
+	return m
+}
+
+// binaryValue reduces v to the types the binary encoders write: nil, bool,
+// int64, uint64, float32, float64, string, []byte, time.Time,
+// map[string]interface{} and []interface{}
+func binaryValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil, bool, int64, uint64, float32, float64, string, []byte, time.Time:
+		return v
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint:
+		return uint64(v)
+	case uint8:
+		return uint64(v)
+	case uint16:
+		return uint64(v)
+	case uint32:
+		return uint64(v)
+	case time.Duration:
+		return int64(v)
+	case Fields:
+		return binaryMap(v)
+	case map[string]interface{}:
+		return binaryMap(v)
+	case map[string]string:
+		m := make(map[string]interface{}, len(v))
+		for k, s := range v {
+			m[k] = s
+		}
+		return m
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = binaryValue(item)
+		}
+		return items
+	case []string:
+		items := make([]interface{}, len(v))
+		for i, s := range v {
+			items[i] = s
+		}
+		return items
+	case StackTrace:
+		items := make([]interface{}, len(v))
+		for i, fr := range v {
+			items[i] = map[string]interface{}{
+				"function": fr.Function,
+				"file":     fr.File,
+				"line":     int64(fr.Line),
+			}
+		}
+		return items
+	case json.Marshaler:
+		return binaryJSONValue(v)
+	case error, fmt.Stringer:
+		if isNilPointer(v) {
+			return nil
+		}
+		return fieldString(v)
+	default:
+		return binaryJSONValue(v)
+	}
+}
+
+// binaryMap normalizes the values of a map
+func binaryMap(fields map[string]interface{}) map[string]interface{} {
+	m := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		m[k] = binaryValue(v)
+	}
+	return m
+}
+
+// binaryJSONValue converts v through its JSON representation, falling back
+// to fmt.Sprint if it cannot be marshaled
+func binaryJSONValue(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return fmt.Sprint(v)
+	}
+	return binaryValue(decoded)
+}
+
+// entryFromBinaryMap builds an entry from a decoded schema map
+func entryFromBinaryMap(m map[string]interface{}) (*Entry, error) {
+	entry := &Entry{}
+
+	if t, ok := m[binaryKeyTime].(time.Time); ok {
+		entry.Time = t
+	} else if m[binaryKeyTime] != nil {
+		return nil, fmt.Errorf("invalid %s: %T", binaryKeyTime, m[binaryKeyTime])
+	}
+
+	name, _ := m[binaryKeyLevelName].(string)
+	var value int
+	switch v := m[binaryKeyLevel].(type) {
+	case int64:
+		value = int(v)
+	case uint64:
+		value = int(v)
+	case nil:
+	default:
+		return nil, fmt.Errorf("invalid %s: %T", binaryKeyLevel, v)
+	}
+	if lvl, ok := ParseLevel(name); ok && lvl.Value == value {
+		entry.Level = lvl
+	} else {
+		entry.Level = Level{Name: name, Value: value}
+	}
+
+	entry.Message, _ = m[binaryKeyMessage].(string)
+	entry.Caller, _ = m[binaryKeyCaller].(string)
+	if fields, ok := m[binaryKeyFields].(map[string]interface{}); ok {
+		entry.Fields = Fields(fields)
+	}
+	return entry, nil
+}
+
+// binaryReader reads records from a stream, skipping the newline that
+// line-oriented handlers write after each formatted entry
+type binaryReader struct {
+	r *bufio.Reader
+}
+
+func newBinaryReader(r io.Reader) binaryReader {
+	if br, ok := r.(*bufio.Reader); ok {
+		return binaryReader{br}
+	}
+	return binaryReader{bufio.NewReader(r)}
+}
+
+// skipSeparators discards newlines before the next record, returning io.EOF
+// if the stream ends first
+func (br binaryReader) skipSeparators() error {
+	for {
+		b, err := br.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b != '\n' {
+			return br.r.UnreadByte()
+		}
+	}
+}
+
+func (br binaryReader) readByte() (byte, error) {
+	b, err := br.r.ReadByte()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return b, err
+}
+
+// readN reads exactly n bytes
+func (br binaryReader) readN(n uint64) ([]byte, error) {
+	if n > binaryMaxLength {
+		return nil, fmt.Errorf("length %d exceeds limit", n)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(br.r, buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+// readUint reads a big-endian unsigned integer of size bytes
+func (br binaryReader) readUint(size int) (uint64, error) {
+	buf, err := br.readN(uint64(size))
+	if err != nil {
+		return 0, err
+	}
+	var n uint64
+	for _, b := range buf {
+		n = n<<8 | uint64(b)
+	}
+	return n, nil
+}
+
+// appendUintBE appends n as a big-endian integer of size bytes
+func appendUintBE(buf []byte, n uint64, size int) []byte {
+	for i := size - 1; i >= 0; i-- {
+		buf = append(buf, byte(n>>(8*uint(i))))
+	}
+	return buf
+}
+
+// binaryCapacity bounds the initial capacity for a declared element count
+func binaryCapacity(n uint64) int {
+	if n > 1024 {
+		return 1024
+	}
+	return int(n)
+}
@@ -0,0 +1,309 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// CBOR major types
+const (
+	cborUint   = 0
+	cborNegInt = 1
+	cborBytes  = 2
+	cborText   = 3
+	cborArray  = 4
+	cborMap    = 5
+	cborTag    = 6
+	cborSimple = 7
+)
+
+// cborTagEpoch is the tag for epoch-based date/time
+const cborTagEpoch = 1
+
+// CBORFormatter formats log entries as CBOR (RFC 8949) maps using the
+// binary schema described in binary.go. Timestamps use tag 1 with integer
+// seconds, or floating-point seconds with microsecond precision when the
+// time has a fractional part.
+type CBORFormatter struct{}
+
+// NewCBORFormatter creates a new CBOR formatter.
+func NewCBORFormatter() Formatter {
+	return &CBORFormatter{}
+}
+
+// Format implements the Formatter interface for CBOR output
+func (f *CBORFormatter) Format(entry *Entry) ([]byte, error) {
+	entry = stripMetrics(entry)
+	return appendCBOR(make([]byte, 0, 128), binaryEntryMap(entry), 0)
+}
+
+// appendCBOR appends the CBOR encoding of a normalized value
+func appendCBOR(buf []byte, v interface{}, depth int) ([]byte, error) {
+	if depth > binaryMaxDepth {
+		return buf, errBinaryTooDeep
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return append(buf, 0xf6), nil
+	case bool:
+		if v {
+			return append(buf, 0xf5), nil
+		}
+		return append(buf, 0xf4), nil
+	case int64:
+		if v >= 0 {
+			return appendCBORHead(buf, cborUint, uint64(v)), nil
+		}
+		return appendCBORHead(buf, cborNegInt, uint64(-1-v)), nil
+	case uint64:
+		return appendCBORHead(buf, cborUint, v), nil
+	case float32:
+		return appendUintBE(append(buf, 0xfa), uint64(math.Float32bits(v)), 4), nil
+	case float64:
+		return appendUintBE(append(buf, 0xfb), math.Float64bits(v), 8), nil
+	case string:
+		return append(appendCBORHead(buf, cborText, uint64(len(v))), v...), nil
+	case []byte:
+		return append(appendCBORHead(buf, cborBytes, uint64(len(v))), v...), nil
+	case time.Time:
+		buf = appendCBORHead(buf, cborTag, cborTagEpoch)
+		if v.Nanosecond() == 0 {
+			return appendCBOR(buf, v.Unix(), depth+1)
+		}
+		return appendCBOR(buf, float64(v.UnixNano()/int64(time.Microsecond))/1e6, depth+1)
+	case []interface{}:
+		buf = appendCBORHead(buf, cborArray, uint64(len(v)))
+		var err error
+		for _, item := range v {
+			if buf, err = appendCBOR(buf, item, depth+1); err != nil {
+				return buf, err
+			}
+		}
+		return buf, nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		buf = appendCBORHead(buf, cborMap, uint64(len(v)))
+		var err error
+		for _, k := range keys {
+			buf = append(appendCBORHead(buf, cborText, uint64(len(k))), k...)
+			if buf, err = appendCBOR(buf, v[k], depth+1); err != nil {
+				return buf, err
+			}
+		}
+		return buf, nil
+	default:
+		return appendCBOR(buf, binaryValue(v), depth+1)
+	}
+}
+
+// appendCBORHead appends the initial byte and argument of a data item
+func appendCBORHead(buf []byte, major byte, n uint64) []byte {
+	major <<= 5
+	switch {
+	case n < 24:
+		return append(buf, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(buf, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return appendUintBE(append(buf, major|25), n, 2)
+	case n <= math.MaxUint32:
+		return appendUintBE(append(buf, major|26), n, 4)
+	default:
+		return appendUintBE(append(buf, major|27), n, 8)
+	}
+}
+
+// CBORDecoder reads entries written by CBORFormatter from a stream.
+type CBORDecoder struct {
+	r binaryReader
+}
+
+// NewCBORDecoder creates a decoder reading from r. Newlines written between
+// entries by line-oriented handlers are skipped.
+func NewCBORDecoder(r io.Reader) *CBORDecoder {
+	return &CBORDecoder{r: newBinaryReader(r)}
+}
+
+// DecodeCBOREntry decodes a single entry written by CBORFormatter
+func DecodeCBOREntry(data []byte) (*Entry, error) {
+	return NewCBORDecoder(bytes.NewReader(data)).Decode()
+}
+
+// Decode reads the next entry, returning io.EOF at the end of the stream
+func (d *CBORDecoder) Decode() (*Entry, error) {
+	if err := d.r.skipSeparators(); err != nil {
+		return nil, err
+	}
+	v, err := d.decodeValue(0)
+	if err != nil {
+		return nil, fmt.Errorf("cbor: %w", err)
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("cbor: expected map, got %T", v)
+	}
+	return entryFromBinaryMap(m)
+}
+
+// decodeValue reads one data item. Indefinite-length items are not
+// supported since CBORFormatter never writes them.
+func (d *CBORDecoder) decodeValue(depth int) (interface{}, error) {
+	if depth > binaryMaxDepth {
+		return nil, errBinaryTooDeep
+	}
+	b, err := d.r.readByte()
+	if err != nil {
+		return nil, err
+	}
+	major, info := b>>5, b&0x1f
+
+	if major == cborSimple {
+		switch info {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22, 23:
+			return nil, nil
+		case 25:
+			n, err := d.r.readUint(2)
+			return float64(cborHalfToFloat(uint16(n))), err
+		case 26:
+			n, err := d.r.readUint(4)
+			return float64(math.Float32frombits(uint32(n))), err
+		case 27:
+			n, err := d.r.readUint(8)
+			return math.Float64frombits(n), err
+		}
+		return nil, fmt.Errorf("unsupported simple value %d", info)
+	}
+
+	var n uint64
+	switch {
+	case info < 24:
+		n = uint64(info)
+	case info <= 27:
+		if n, err = d.r.readUint(1 << (info - 24)); err != nil {
+			return nil, err
+		}
+	case info == 31:
+		return nil, fmt.Errorf("indefinite-length items are not supported")
+	default:
+		return nil, fmt.Errorf("invalid additional information %d", info)
+	}
+
+	switch major {
+	case cborUint:
+		if n <= math.MaxInt64 {
+			return int64(n), nil
+		}
+		return n, nil
+	case cborNegInt:
+		if n > math.MaxInt64 {
+			return nil, fmt.Errorf("negative integer out of range")
+		}
+		return -1 - int64(n), nil
+	case cborBytes:
+		return d.r.readN(n)
+	case cborText:
+		data, err := d.r.readN(n)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	case cborArray:
+		if n > binaryMaxLength {
+			return nil, fmt.Errorf("array length %d exceeds limit", n)
+		}
+		items := make([]interface{}, 0, binaryCapacity(n))
+		for i := uint64(0); i < n; i++ {
+			item, err := d.decodeValue(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case cborMap:
+		if n > binaryMaxLength {
+			return nil, fmt.Errorf("map length %d exceeds limit", n)
+		}
+		m := make(map[string]interface{}, binaryCapacity(n))
+		for i := uint64(0); i < n; i++ {
+			key, err := d.decodeValue(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			value, err := d.decodeValue(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if s, ok := key.(string); ok {
+				m[s] = value
+			} else {
+				m[fmt.Sprint(key)] = value
+			}
+		}
+		return m, nil
+	default: // cborTag
+		value, err := d.decodeValue(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return cborTagged(n, value)
+	}
+}
+
+// cborTagged interprets a tagged value; tags other than date/time return
+// the value unchanged
+func cborTagged(tag uint64, value interface{}) (interface{}, error) {
+	switch tag {
+	case 0:
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid date/time string: %T", value)
+		}
+		return time.Parse(time.RFC3339Nano, s)
+	case cborTagEpoch:
+		switch v := value.(type) {
+		case int64:
+			return time.Unix(v, 0), nil
+		case uint64:
+			return time.Unix(int64(v), 0), nil
+		case float64:
+			sec, frac := math.Modf(v)
+			return time.Unix(int64(sec), int64(math.Round(frac*1e6))*int64(time.Microsecond)), nil
+		}
+		return nil, fmt.Errorf("invalid epoch date/time: %T", value)
+	}
+	return value, nil
+}
+
+// cborHalfToFloat converts an IEEE 754 half-precision float
+func cborHalfToFloat(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	mant := uint32(h) & 0x3ff
+
+	switch exp {
+	case 0:
+		f := float32(mant) / (1 << 24)
+		if sign != 0 {
+			return -f
+		}
+		return f
+	case 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | mant<<13)
+	}
+	return math.Float32frombits(sign | (exp+112)<<23 | mant<<13)
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -14,6 +15,7 @@ import (
 type Config struct {
 	Level           string            `yaml:"level" json:"level"`
 	Format          string            `yaml:"format" json:"format"`
+	Pattern         string            `yaml:"pattern" json:"pattern"`
 	Output          string            `yaml:"output" json:"output"`
 	IncludeCaller   bool              `yaml:"include_caller" json:"include_caller"`
 	IncludeStack    bool              `yaml:"include_stack" json:"include_stack"`
@@ -21,6 +23,9 @@ type Config struct {
 	UseColors       bool              `yaml:"use_colors" json:"use_colors"`
 	DefaultFields   map[string]string `yaml:"default_fields" json:"default_fields"`
 
+	// Size limits applied to every entry
+	Limits Limits `yaml:"limits" json:"limits"`
+
 	// File handler specific
 	FileConfig FileConfig `yaml:"file" json:"file"`
 
@@ -36,10 +41,17 @@ type Config struct {
 
 // FileConfig represents file handler configuration
 type FileConfig struct {
-	Path     string `yaml:"path" json:"path"`
-	MaxSize  int64  `yaml:"max_size" json:"max_size"`
-	MaxFiles int    `yaml:"max_files" json:"max_files"`
-	Rotate   bool   `yaml:"rotate" json:"rotate"`
+	Path           string `yaml:"path" json:"path"`
+	MaxSize        int64  `yaml:"max_size" json:"max_size"`
+	MaxFiles       int    `yaml:"max_files" json:"max_files"`
+	Rotate         bool   `yaml:"rotate" json:"rotate"`
+	Lock           bool   `yaml:"lock" json:"lock"`
+	ReopenOnSIGHUP bool   `yaml:"reopen_on_sighup" json:"reopen_on_sighup"`
+
+	// BufferSize enables buffered writes of this many bytes (0 = unbuffered)
+	BufferSize      int    `yaml:"buffer_size" json:"buffer_size"`
+	FlushIntervalMs int    `yaml:"flush_interval_ms" json:"flush_interval_ms"`
+	Fsync           string `yaml:"fsync" json:"fsync"` // "never", "interval" or "every-error"
 }
 
 // HTTPConfig represents HTTP handler configuration
@@ -96,6 +108,7 @@ func LoadConfigFromEnv() *Config {
 	config := &Config{
 		Level:           getEnv("LOG_LEVEL", "info"),
 		Format:          getEnv("LOG_FORMAT", "text"),
+		Pattern:         getEnv("LOG_PATTERN", ""),
 		Output:          getEnv("LOG_OUTPUT", "console"),
 		IncludeCaller:   getEnvBool("LOG_INCLUDE_CALLER", false),
 		IncludeStack:    getEnvBool("LOG_INCLUDE_STACK", false),
@@ -103,11 +116,27 @@ func LoadConfigFromEnv() *Config {
 		UseColors:       getEnvBool("LOG_USE_COLORS", true),
 		DefaultFields:   parseEnvFields("LOG_DEFAULT_FIELDS"),
 
+		Limits: Limits{
+			MaxMessageLength: getEnvInt("LOG_MAX_MESSAGE_LENGTH", 0),
+			MaxFieldLength:   getEnvInt("LOG_MAX_FIELD_LENGTH", 0),
+			MaxFields:        getEnvInt("LOG_MAX_FIELDS", 0),
+			MaxDepth:         getEnvInt("LOG_MAX_DEPTH", 0),
+			MaxEntrySize:     getEnvInt("LOG_MAX_ENTRY_SIZE", 0),
+			Marker:           getEnv("LOG_TRUNCATION_MARKER", ""),
+		},
+
 		FileConfig: FileConfig{
 			Path:     getEnv("LOG_FILE_PATH", "app.log"),
 			MaxSize:  getEnvInt64("LOG_FILE_MAX_SIZE", 10*1024*1024),
 			MaxFiles: getEnvInt("LOG_FILE_MAX_FILES", 5),
 			Rotate:   getEnvBool("LOG_FILE_ROTATE", false),
+			Lock:     getEnvBool("LOG_FILE_LOCK", false),
+
+			ReopenOnSIGHUP: getEnvBool("LOG_FILE_REOPEN_ON_SIGHUP", false),
+
+			BufferSize:      getEnvInt("LOG_FILE_BUFFER_SIZE", 0),
+			FlushIntervalMs: getEnvInt("LOG_FILE_FLUSH_INTERVAL_MS", 1000),
+			Fsync:           getEnv("LOG_FILE_FSYNC", "never"),
 		},
 
 		HTTPConfig: HTTPConfig{
@@ -139,6 +168,9 @@ func (c *Config) overrideFromEnv() {
 	if val := os.Getenv("LOG_FORMAT"); val != "" {
 		c.Format = val
 	}
+	if val := os.Getenv("LOG_PATTERN"); val != "" {
+		c.Pattern = val
+	}
 	if val := os.Getenv("LOG_OUTPUT"); val != "" {
 		c.Output = val
 	}
@@ -165,6 +197,18 @@ func (c *Config) ToLogger() (Logger, error) {
 		formatter = NewJSONFormatter()
 	case "text":
 		formatter = NewTextFormatter()
+	case "logfmt":
+		formatter = NewLogfmtFormatter()
+	case "datadog":
+		formatter = NewDatadogFormatter()
+	case "dev":
+		formatter = NewDevFormatter()
+	case "pattern":
+		pattern, err := NewPatternFormatter(c.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		formatter = pattern
 	default:
 		return nil, fmt.Errorf("invalid format: %s", c.Format)
 	}
@@ -175,15 +219,34 @@ func (c *Config) ToLogger() (Logger, error) {
 	case "console":
 		handler = NewConsoleHandler()
 	case "file":
+		var fileOpts []FileOption
+		if c.FileConfig.Lock {
+			fileOpts = append(fileOpts, WithFileLocking())
+		}
+		if c.FileConfig.ReopenOnSIGHUP {
+			fileOpts = append(fileOpts, WithFileReopenOnSIGHUP())
+		}
+		if c.FileConfig.BufferSize > 0 {
+			fileOpts = append(fileOpts, WithFileBuffer(c.FileConfig.BufferSize))
+		}
+		if c.FileConfig.FlushIntervalMs > 0 {
+			fileOpts = append(fileOpts, WithFileFlushInterval(time.Duration(c.FileConfig.FlushIntervalMs)*time.Millisecond))
+		}
+		syncPolicy, err := ParseFileSyncPolicy(c.FileConfig.Fsync)
+		if err != nil {
+			return nil, err
+		}
+		fileOpts = append(fileOpts, WithFileSync(syncPolicy))
+
 		if c.FileConfig.Rotate {
 			var err error
-			handler, err = NewRotatingFileHandler(c.FileConfig.Path, c.FileConfig.MaxSize, c.FileConfig.MaxFiles)
+			handler, err = NewRotatingFileHandler(c.FileConfig.Path, c.FileConfig.MaxSize, c.FileConfig.MaxFiles, fileOpts...)
 			if err != nil {
 				return nil, fmt.Errorf("failed to create rotating file handler: %w", err)
 			}
 		} else {
 			var err error
-			handler, err = NewFileHandler(c.FileConfig.Path)
+			handler, err = NewFileHandler(c.FileConfig.Path, fileOpts...)
 			if err != nil {
 				return nil, fmt.Errorf("failed to create file handler: %w", err)
 			}
@@ -216,6 +279,7 @@ func (c *Config) ToLogger() (Logger, error) {
 		WithCaller(c.IncludeCaller),
 		WithStacktrace(c.IncludeStack),
 		WithDefaultFields(fields),
+		WithLimits(c.Limits),
 	)
 
 	return logger, nil
@@ -413,7 +413,7 @@ func formatDuration(d time.Duration) string {
 // DashboardHook creates a hook that feeds data to the dashboard
 func NewDashboardHook(dashboard *Dashboard) Hook {
 	return func(entry *Entry) {
-		dashboard.AddRecentLog(entry.Level, entry.Message, entry.Fields)
+		dashboard.AddRecentLog(entry.Level, entry.Message, stripMetrics(entry).Fields)
 	}
 }
 
@@ -429,6 +429,7 @@ func StartDashboard(dashboard *Dashboard) error {
 // DashboardHandler wraps another handler and feeds data to the dashboard
 type DashboardHandler struct {
 	handler   Handler
+	processor Processor
 	dashboard *Dashboard
 }
 
@@ -436,15 +437,20 @@ type DashboardHandler struct {
 func NewDashboardHandler(handler Handler, dashboard *Dashboard) Handler {
 	return &DashboardHandler{
 		handler:   handler,
+		processor: DashboardProcessor(dashboard),
 		dashboard: dashboard,
 	}
 }
 
 // Handle implements the Handler interface with dashboard integration
 func (dh *DashboardHandler) Handle(entry *Entry) error {
-	// Feed to dashboard
-	dh.dashboard.AddRecentLog(entry.Level, entry.Message, entry.Fields)
+	return processAndHandle(dh.processor, dh.handler, entry)
+}
 
-	// Handle normally
-	return dh.handler.Handle(entry)
+// DashboardProcessor feeds every entry to the dashboard's recent logs
+func DashboardProcessor(dashboard *Dashboard) Processor {
+	return ProcessorFunc(func(entry *Entry) (*Entry, error) {
+		dashboard.AddRecentLog(entry.Level, entry.Message, stripMetrics(entry).Fields)
+		return entry, nil
+	})
 }
@@ -0,0 +1,203 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// DatadogFormatterOption is a functional option for DatadogFormatter configuration.
+type DatadogFormatterOption func(*DatadogFormatter)
+
+// DatadogFormatter formats log entries as JSON using Datadog's reserved
+// attributes.
+//
+// Service, env and version come from the options, then from "service",
+// "env"/"environment" and "version" fields (e.g. Config.DefaultFields), then
+// from DD_SERVICE, DD_ENV and DD_VERSION. Trace and span IDs from the entry's
+// span or trace context are written as decimal dd.trace_id and dd.span_id,
+// using the low 64 bits of 128-bit trace IDs as Datadog does.
+type DatadogFormatter struct {
+	Service string
+	Env     string
+	Version string
+
+	// Host defaults to DD_HOSTNAME or os.Hostname
+	Host string
+
+	// fallbacks from DD_SERVICE, DD_ENV and DD_VERSION
+	envService, envEnv, envVersion string
+}
+
+// NewDatadogFormatter creates a new Datadog formatter with options.
+func NewDatadogFormatter(opts ...DatadogFormatterOption) Formatter {
+	f := &DatadogFormatter{
+		Host:       os.Getenv("DD_HOSTNAME"),
+		envService: os.Getenv("DD_SERVICE"),
+		envEnv:     os.Getenv("DD_ENV"),
+		envVersion: os.Getenv("DD_VERSION"),
+	}
+	if f.Host == "" {
+		f.Host, _ = os.Hostname()
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// WithDatadogService sets the service, env and version, overriding fields
+// and DD_* environment variables. Empty values are ignored.
+func WithDatadogService(service, env, version string) DatadogFormatterOption {
+	return func(f *DatadogFormatter) {
+		if service != "" {
+			f.Service = service
+		}
+		if env != "" {
+			f.Env = env
+		}
+		if version != "" {
+			f.Version = version
+		}
+	}
+}
+
+// WithDatadogHost sets the reported host.
+func WithDatadogHost(host string) DatadogFormatterOption {
+	return func(f *DatadogFormatter) {
+		f.Host = host
+	}
+}
+
+// DatadogStatus maps a level to a Datadog log status
+func DatadogStatus(level Level) string {
+	switch {
+	case level.Value > PanicLevel.Value:
+		return "emergency"
+	case level.Value >= PanicLevel.Value:
+		return "alert"
+	case level.Value >= FatalLevel.Value:
+		return "critical"
+	case level.Value >= ErrorLevel.Value:
+		return "error"
+	case level.Value >= WarnLevel.Value:
+		return "warning"
+	case level.Value > InfoLevel.Value:
+		return "notice"
+	case level.Value >= InfoLevel.Value:
+		return "info"
+	default:
+		return "debug"
+	}
+}
+
+// DatadogID converts a hex trace or span ID to Datadog's decimal form,
+// keeping the low 64 bits. It returns "" if id is not valid hex.
+func DatadogID(id string) string {
+	if len(id) > 16 {
+		id = id[len(id)-16:]
+	}
+	n, err := strconv.ParseUint(id, 16, 64)
+	if err != nil {
+		return ""
+	}
+	return strconv.FormatUint(n, 10)
+}
+
+// Format implements the Formatter interface for Datadog output
+func (f *DatadogFormatter) Format(entry *Entry) ([]byte, error) {
+	entry = stripMetrics(entry)
+	doc := map[string]interface{}{
+		"message":   entry.Message,
+		"status":    DatadogStatus(entry.Level),
+		"timestamp": entry.Time.UTC().Format(time.RFC3339Nano),
+	}
+	if f.Host != "" {
+		doc["host"] = f.Host
+	}
+	if entry.Caller != "" {
+		doc["logger"] = map[string]interface{}{"caller": entry.Caller}
+	}
+
+	service, env, version := f.Service, f.Env, f.Version
+	errorFields := make(map[string]interface{})
+
+	for k, v := range entry.Fields {
+		switch k {
+		case "service":
+			if service == "" {
+				service = fmt.Sprint(v)
+			}
+			continue
+		case "env", "environment":
+			if env == "" {
+				env = fmt.Sprint(v)
+			}
+			continue
+		case "version":
+			if version == "" {
+				version = fmt.Sprint(v)
+			}
+			continue
+		case "stacktrace":
+			errorFields["stack"] = fmt.Sprint(v)
+			continue
+		case "error", "err":
+			if err, ok := v.(error); ok {
+				errorFields["message"] = errorValue(err)
+				errorFields["kind"] = fmt.Sprintf("%T", err)
+			} else {
+				errorFields["message"] = fmt.Sprint(v)
+			}
+			continue
+		}
+
+		if err, ok := v.(error); ok {
+			v = errorValue(err)
+		}
+		if _, reserved := doc[k]; reserved || k == "dd" {
+			k = "fields." + k
+		}
+		doc[k] = v
+	}
+
+	if service == "" {
+		service = f.envService
+	}
+	if env == "" {
+		env = f.envEnv
+	}
+	if version == "" {
+		version = f.envVersion
+	}
+
+	dd := make(map[string]interface{})
+	if service != "" {
+		doc["service"] = service
+		dd["service"] = service
+	}
+	if env != "" {
+		dd["env"] = env
+	}
+	if version != "" {
+		dd["version"] = version
+	}
+	if traceID, spanID := entryTraceIDs(entry); traceID != "" {
+		if id := DatadogID(traceID); id != "" {
+			dd["trace_id"] = id
+		}
+		if id := DatadogID(spanID); id != "" {
+			dd["span_id"] = id
+		}
+	}
+	if len(dd) > 0 {
+		doc["dd"] = dd
+	}
+	if len(errorFields) > 0 {
+		doc["error"] = errorFields
+	}
+
+	return json.Marshal(doc)
+}
@@ -0,0 +1,429 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// DevTimeMode selects how DevFormatter renders timestamps
+type DevTimeMode int
+
+const (
+	// DevTimeShort renders the time of day, e.g. 15:04:05.000
+	DevTimeShort DevTimeMode = iota
+	// DevTimeRelative renders the time since the formatter was created, e.g. +1.250s
+	DevTimeRelative
+	// DevTimeNone leaves the timestamp out
+	DevTimeNone
+)
+
+// devMaxDepth limits how deep nested values are rendered
+const devMaxDepth = 8
+
+// DevFormatterOption is a functional option for DevFormatter configuration.
+type DevFormatterOption func(*DevFormatter)
+
+// DevFormatter renders log entries for reading in a terminal during local
+// development:
+//
+//	12:04:05.123 INFO  order placed                   status=paid user_id=42  (orders/api.go:42)
+//	    request:
+//	        items:
+//	            -
+//	                SKU: A-1
+//	                Qty: 2
+//	        method: POST
+//	    stacktrace:
+//	        main.handler(...)
+//	            cmd/api/main.go:31
+//
+// Short scalar fields stay on the first line; long or multi-line values,
+// maps, structs, slices and stack traces are rendered on indented lines.
+// Caller paths are shortened relative to the module root.
+type DevFormatter struct {
+	UseColors bool
+	TimeMode  DevTimeMode
+
+	// TimeFormat is used by DevTimeShort (default "15:04:05.000")
+	TimeFormat string
+
+	// MessageWidth pads the message so inline fields line up (default 30)
+	MessageWidth int
+
+	// InlineWidth is the longest value kept on the first line (default 40)
+	InlineWidth int
+
+	// ModuleRoot is stripped from caller and stack trace paths (default the
+	// directory of the nearest go.mod above the working directory)
+	ModuleRoot string
+
+	LevelColors map[Level]*color.Color
+	KeyColor    *color.Color
+	ValueColor  *color.Color
+	DimColor    *color.Color
+
+	start time.Time
+}
+
+// NewDevFormatter creates a new developer console formatter with options.
+func NewDevFormatter(opts ...DevFormatterOption) Formatter {
+	f := &DevFormatter{
+		UseColors:    true,
+		TimeMode:     DevTimeShort,
+		TimeFormat:   "15:04:05.000",
+		MessageWidth: 30,
+		InlineWidth:  40,
+		ModuleRoot:   findModuleRoot(),
+		LevelColors: map[Level]*color.Color{
+			DebugLevel: color.New(color.FgCyan),
+			InfoLevel:  color.New(color.FgGreen),
+			WarnLevel:  color.New(color.FgYellow),
+			ErrorLevel: color.New(color.FgRed),
+			FatalLevel: color.New(color.FgMagenta, color.Bold),
+			PanicLevel: color.New(color.FgHiMagenta, color.Bold),
+		},
+		KeyColor:   color.New(color.FgBlue),
+		ValueColor: color.New(color.FgWhite),
+		DimColor:   color.New(color.Faint),
+		start:      time.Now(),
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// WithDevColors enables or disables colors.
+func WithDevColors(enabled bool) DevFormatterOption {
+	return func(f *DevFormatter) {
+		f.UseColors = enabled
+	}
+}
+
+// WithDevTimeMode sets how timestamps are rendered.
+func WithDevTimeMode(mode DevTimeMode) DevFormatterOption {
+	return func(f *DevFormatter) {
+		f.TimeMode = mode
+	}
+}
+
+// WithDevMessageWidth sets the column inline fields start at.
+func WithDevMessageWidth(width int) DevFormatterOption {
+	return func(f *DevFormatter) {
+		f.MessageWidth = width
+	}
+}
+
+// WithDevInlineWidth sets the longest value kept on the first line.
+func WithDevInlineWidth(width int) DevFormatterOption {
+	return func(f *DevFormatter) {
+		f.InlineWidth = width
+	}
+}
+
+// WithDevModuleRoot sets the path stripped from callers and stack traces.
+func WithDevModuleRoot(root string) DevFormatterOption {
+	return func(f *DevFormatter) {
+		f.ModuleRoot = root
+	}
+}
+
+// WithDevKeyColors sets the colors of field keys and values.
+func WithDevKeyColors(key, value *color.Color) DevFormatterOption {
+	return func(f *DevFormatter) {
+		f.KeyColor = key
+		f.ValueColor = value
+	}
+}
+
+// Format implements the Formatter interface for developer console output
+func (f *DevFormatter) Format(entry *Entry) ([]byte, error) {
+	entry = stripMetrics(entry)
+	var buf bytes.Buffer
+
+	switch f.TimeMode {
+	case DevTimeShort:
+		layout := f.TimeFormat
+		if layout == "" {
+			layout = "15:04:05.000"
+		}
+		buf.WriteString(f.paint(f.DimColor, entry.Time.Format(layout)))
+		buf.WriteByte(' ')
+	case DevTimeRelative:
+		elapsed := entry.Time.Sub(f.start)
+		buf.WriteString(f.paint(f.DimColor, fmt.Sprintf("+%.3fs", elapsed.Seconds())))
+		buf.WriteByte(' ')
+	}
+
+	level := fmt.Sprintf("%-5s", strings.ToUpper(entry.Level.Name))
+	buf.WriteString(f.paint(f.LevelColors[entry.Level], level))
+	buf.WriteByte(' ')
+	buf.WriteString(entry.Message)
+
+	keys := make([]string, 0, len(entry.Fields))
+	for k := range entry.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var inline, block []string
+	for _, k := range keys {
+		if k != "stacktrace" && f.isInline(entry.Fields[k]) {
+			inline = append(inline, k)
+		} else {
+			block = append(block, k)
+		}
+	}
+
+	if len(inline) > 0 {
+		if pad := f.MessageWidth - len([]rune(entry.Message)); pad > 0 {
+			buf.WriteString(strings.Repeat(" ", pad))
+		}
+		for _, k := range inline {
+			buf.WriteByte(' ')
+			buf.WriteString(f.paint(f.KeyColor, k))
+			buf.WriteByte('=')
+			buf.WriteString(f.paint(f.ValueColor, devInlineValue(entry.Fields[k])))
+		}
+	}
+
+	if entry.Caller != "" {
+		buf.WriteString("  ")
+		buf.WriteString(f.paint(f.DimColor, "("+f.shortenPath(entry.Caller)+")"))
+	}
+
+	for _, k := range block {
+		buf.WriteString("\n    ")
+		buf.WriteString(f.paint(f.KeyColor, k))
+		buf.WriteByte(':')
+		if st, ok := entry.Fields[k].(StackTrace); ok {
+			f.writeFrames(&buf, st, 8)
+			continue
+		}
+		if k == "stacktrace" {
+			f.writeStack(&buf, fmt.Sprint(entry.Fields[k]), 8)
+			continue
+		}
+		f.writeBlock(&buf, entry.Fields[k], 8, 0)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// isInline reports whether v is a short scalar that fits on the first line
+func (f *DevFormatter) isInline(v interface{}) bool {
+	if devIsComposite(v) {
+		return false
+	}
+	s := devScalar(v)
+	return len(s) <= f.InlineWidth && !strings.Contains(s, "\n")
+}
+
+// writeBlock writes v after a "key:" at the given indentation, either on
+// the same line for short scalars or on following indented lines
+func (f *DevFormatter) writeBlock(buf *bytes.Buffer, v interface{}, indent, depth int) {
+	pad := "\n" + strings.Repeat(" ", indent)
+
+	if depth >= devMaxDepth {
+		buf.WriteString(" …")
+		return
+	}
+
+	if !devIsComposite(v) {
+		s := devScalar(v)
+		if !strings.Contains(s, "\n") {
+			buf.WriteByte(' ')
+			buf.WriteString(f.paint(f.ValueColor, s))
+			return
+		}
+		buf.WriteString(" |")
+		for _, line := range strings.Split(strings.TrimRight(s, "\n"), "\n") {
+			buf.WriteString(pad)
+			buf.WriteString(f.paint(f.ValueColor, line))
+		}
+		return
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	switch rv.Kind() {
+	case reflect.Map:
+		keys := rv.MapKeys()
+		if len(keys) == 0 {
+			buf.WriteString(" {}")
+			return
+		}
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, k := range keys {
+			buf.WriteString(pad)
+			buf.WriteString(f.paint(f.KeyColor, fmt.Sprint(k)))
+			buf.WriteByte(':')
+			f.writeBlock(buf, rv.MapIndex(k).Interface(), indent+4, depth+1)
+		}
+	case reflect.Struct:
+		t := rv.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).PkgPath != "" {
+				continue
+			}
+			buf.WriteString(pad)
+			buf.WriteString(f.paint(f.KeyColor, t.Field(i).Name))
+			buf.WriteByte(':')
+			f.writeBlock(buf, rv.Field(i).Interface(), indent+4, depth+1)
+		}
+	case reflect.Slice, reflect.Array:
+		if rv.Len() == 0 {
+			buf.WriteString(" []")
+			return
+		}
+		for i := 0; i < rv.Len(); i++ {
+			buf.WriteString(pad)
+			buf.WriteString(f.paint(f.DimColor, "-"))
+			f.writeBlock(buf, rv.Index(i).Interface(), indent+4, depth+1)
+		}
+	}
+}
+
+// writeStack renders a runtime.Stack trace with one function per line and
+// its shortened file:line below it
+func (f *DevFormatter) writeStack(buf *bytes.Buffer, stack string, indent int) {
+	pad := "\n" + strings.Repeat(" ", indent)
+	for _, line := range strings.Split(strings.TrimRight(stack, "\n"), "\n") {
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "goroutine "):
+			buf.WriteString(pad)
+			buf.WriteString(f.paint(f.DimColor, line))
+		case strings.HasPrefix(line, "\t"):
+			location := strings.TrimSpace(line)
+			if i := strings.LastIndex(location, " +0x"); i > 0 {
+				location = location[:i]
+			}
+			buf.WriteString(pad)
+			buf.WriteString("    ")
+			buf.WriteString(f.paint(f.DimColor, f.shortenPath(location)))
+		default:
+			buf.WriteString(pad)
+			buf.WriteString(line)
+		}
+	}
+}
+
+// writeFrames renders a captured StackTrace like writeStack
+func (f *DevFormatter) writeFrames(buf *bytes.Buffer, st StackTrace, indent int) {
+	pad := "\n" + strings.Repeat(" ", indent)
+	for _, fr := range st {
+		buf.WriteString(pad)
+		buf.WriteString(fr.Function)
+		buf.WriteString(pad)
+		buf.WriteString("    ")
+		buf.WriteString(f.paint(f.DimColor, f.shortenPath(fr.File+":"+strconv.Itoa(fr.Line))))
+	}
+}
+
+// shortenPath makes a file path relative to the module root, or to the
+// module cache for dependencies
+func (f *DevFormatter) shortenPath(path string) string {
+	if f.ModuleRoot != "" {
+		if rel, ok := trimPathPrefix(path, f.ModuleRoot); ok {
+			return rel
+		}
+	}
+	if i := strings.Index(path, "/pkg/mod/"); i >= 0 {
+		return path[i+len("/pkg/mod/"):]
+	}
+	return path
+}
+
+// paint colors s if colors are enabled
+func (f *DevFormatter) paint(c *color.Color, s string) string {
+	if !f.UseColors || c == nil {
+		return s
+	}
+	return c.Sprint(s)
+}
+
+// trimPathPrefix returns path relative to dir if it is inside dir
+func trimPathPrefix(path, dir string) (string, bool) {
+	dir = strings.TrimSuffix(filepath.ToSlash(dir), "/") + "/"
+	path = filepath.ToSlash(path)
+	if strings.HasPrefix(path, dir) {
+		return path[len(dir):], true
+	}
+	return path, false
+}
+
+// findModuleRoot returns the directory of the nearest go.mod above the
+// working directory, or "" if there is none
+func findModuleRoot() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// devIsComposite reports whether v is rendered as a nested block
+func devIsComposite(v interface{}) bool {
+	switch v.(type) {
+	case nil, []byte, error, fmt.Stringer, time.Time:
+		return false
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return false
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Map, reflect.Struct, reflect.Slice, reflect.Array:
+		return true
+	}
+	return false
+}
+
+// devScalar renders a scalar value
+func devScalar(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "<nil>"
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case string, []byte, error, fmt.Stringer:
+		return fieldString(v)
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		return devScalar(rv.Elem().Interface())
+	}
+	return fmt.Sprint(v)
+}
+
+// devInlineValue renders a scalar for key=value output, quoting strings
+// that contain spaces or are empty
+func devInlineValue(v interface{}) string {
+	s := devScalar(v)
+	if s == "" || strings.ContainsAny(s, " \t\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
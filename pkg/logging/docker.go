@@ -44,6 +44,7 @@ func NewDockerFormatter(baseFormatter Formatter) *DockerFormatter {
 
 // Format formats log entries with container information
 func (df *DockerFormatter) Format(entry *Entry) ([]byte, error) {
+	entry = stripMetrics(entry)
 	if df.includeContainer && df.containerInfo != nil {
 		// Add container info to fields
 		if entry.Fields == nil {
@@ -216,6 +217,7 @@ func parseDockerLabels(info *ContainerInfo) {
 // ContainerHandler wraps another handler and adds container-specific features
 type ContainerHandler struct {
 	handler       Handler
+	processor     Processor
 	containerInfo *ContainerInfo
 	logPath       string
 	enableStdout  bool
@@ -223,9 +225,11 @@ type ContainerHandler struct {
 
 // NewContainerHandler creates a container-optimized handler
 func NewContainerHandler(handler Handler) *ContainerHandler {
+	info := DetectContainerEnvironment()
 	return &ContainerHandler{
 		handler:       handler,
-		containerInfo: DetectContainerEnvironment(),
+		processor:     ContainerProcessor(info),
+		containerInfo: info,
 		logPath:       getEnv("LOG_PATH", "/var/log/app"),
 		enableStdout:  getEnvBool("ENABLE_STDOUT_LOGS", true),
 	}
@@ -233,22 +237,29 @@ func NewContainerHandler(handler Handler) *ContainerHandler {
 
 // Handle implements the Handler interface with container optimizations
 func (ch *ContainerHandler) Handle(entry *Entry) error {
-	// Add container fields if not already present
-	if ch.containerInfo != nil {
-		if entry.Fields == nil {
-			entry.Fields = make(Fields)
+	return processAndHandle(ch.processor, ch.handler, entry)
+}
+
+// ContainerProcessor adds container fields to entries that do not already have them
+func ContainerProcessor(info *ContainerInfo) Processor {
+	return ProcessorFunc(func(entry *Entry) (*Entry, error) {
+		if info == nil {
+			return entry, nil
 		}
 
+		fields := cloneFields(entry.Fields)
+
 		// Only add if not already set
-		if _, exists := entry.Fields["container_id"]; !exists {
-			entry.Fields["container_id"] = ch.containerInfo.ID
+		if _, exists := fields["container_id"]; !exists {
+			fields["container_id"] = info.ID
 		}
-		if _, exists := entry.Fields["pod_name"]; !exists && ch.containerInfo.PodName != "" {
-			entry.Fields["pod_name"] = ch.containerInfo.PodName
+		if _, exists := fields["pod_name"]; !exists && info.PodName != "" {
+			fields["pod_name"] = info.PodName
 		}
-	}
 
-	return ch.handler.Handle(entry)
+		entry.Fields = fields
+		return entry, nil
+	})
 }
 
 // CloudNativeLogger provides cloud-native logging features
@@ -369,6 +380,9 @@ func (cnl *CloudNativeLogger) SetLevel(level Level)             {}
 func (cnl *CloudNativeLogger) SetHandler(handler Handler)       {}
 func (cnl *CloudNativeLogger) SetFormatter(formatter Formatter) {}
 func (cnl *CloudNativeLogger) AddHook(hook Hook)                {}
+func (cnl *CloudNativeLogger) Metric(name string, value float64, unit string) Logger {
+	return withMetric(cnl, nil, Metric{Name: name, Value: value, Unit: unit})
+}
 
 // fieldLogger wraps CloudNativeLogger with additional fields
 type fieldLogger struct {
@@ -429,6 +443,9 @@ func (fl *fieldLogger) SetLevel(level Level)             {}
 func (fl *fieldLogger) SetHandler(handler Handler)       {}
 func (fl *fieldLogger) SetFormatter(formatter Formatter) {}
 func (fl *fieldLogger) AddHook(hook Hook)                {}
+func (fl *fieldLogger) Metric(name string, value float64, unit string) Logger {
+	return withMetric(fl, fl.fields, Metric{Name: name, Value: value, Unit: unit})
+}
 
 // Helper to create container-optimized configuration
 func NewContainerConfig() *Config {
@@ -0,0 +1,176 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ECSVersion is the Elastic Common Schema version written in ecs.version
+const ECSVersion = "8.11.0"
+
+// ecsTimestampFormat is the layout of @timestamp, always in UTC
+const ecsTimestampFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// ECSFormatterOption is a functional option for ECSFormatter configuration.
+type ECSFormatterOption func(*ECSFormatter)
+
+// ECSFormatter formats log entries as Elastic Common Schema JSON.
+//
+// Entries map to @timestamp, log.level, message, log.origin.file.* from the
+// caller, trace.id and span.id from the entry's span or trace context, and
+// error.* from an "error" or "err" field and the stacktrace field. Remaining
+// fields are nested under Namespace.
+type ECSFormatter struct {
+	// Namespace holds fields that have no ECS mapping (default "fields").
+	// If empty, they are written at the top level unless they clash with an
+	// ECS field.
+	Namespace string
+
+	ServiceName    string
+	ServiceVersion string
+
+	// Container adds container.* and host.* fields
+	Container *ContainerInfo
+}
+
+// NewECSFormatter creates a new ECS formatter with options.
+func NewECSFormatter(opts ...ECSFormatterOption) Formatter {
+	f := &ECSFormatter{
+		Namespace: "fields",
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// WithECSNamespace sets the object that holds fields without an ECS mapping.
+func WithECSNamespace(namespace string) ECSFormatterOption {
+	return func(f *ECSFormatter) {
+		f.Namespace = namespace
+	}
+}
+
+// WithECSService sets service.name and service.version.
+func WithECSService(name, version string) ECSFormatterOption {
+	return func(f *ECSFormatter) {
+		f.ServiceName = name
+		f.ServiceVersion = version
+	}
+}
+
+// WithECSContainerInfo adds container.* and host.* fields from info, e.g.
+// from DetectContainerEnvironment.
+func WithECSContainerInfo(info *ContainerInfo) ECSFormatterOption {
+	return func(f *ECSFormatter) {
+		f.Container = info
+	}
+}
+
+// Format implements the Formatter interface for ECS output
+func (f *ECSFormatter) Format(entry *Entry) ([]byte, error) {
+	entry = stripMetrics(entry)
+	doc := map[string]interface{}{
+		"@timestamp": entry.Time.UTC().Format(ecsTimestampFormat),
+		"message":    entry.Message,
+		"ecs":        map[string]interface{}{"version": ECSVersion},
+	}
+
+	log := map[string]interface{}{"level": entry.Level.String()}
+	if file, line := splitCaller(entry.Caller); file != "" {
+		origin := map[string]interface{}{"name": filepath.Base(file)}
+		if line > 0 {
+			origin["line"] = line
+		}
+		log["origin"] = map[string]interface{}{"file": origin}
+	}
+	doc["log"] = log
+
+	if traceID, spanID := entryTraceIDs(entry); traceID != "" {
+		doc["trace"] = map[string]interface{}{"id": traceID}
+		if spanID != "" {
+			doc["span"] = map[string]interface{}{"id": spanID}
+		}
+	}
+
+	if f.ServiceName != "" {
+		service := map[string]interface{}{"name": f.ServiceName}
+		if f.ServiceVersion != "" {
+			service["version"] = f.ServiceVersion
+		}
+		doc["service"] = service
+	}
+
+	if info := f.Container; info != nil {
+		container := map[string]interface{}{}
+		setIfNotEmpty(container, "id", info.ID)
+		setIfNotEmpty(container, "name", info.Name)
+		if info.Image != "" {
+			image := map[string]interface{}{"name": info.Image}
+			if info.ImageTag != "" {
+				image["tag"] = []string{info.ImageTag}
+			}
+			container["image"] = image
+		}
+		if len(container) > 0 {
+			doc["container"] = container
+		}
+		if info.Hostname != "" {
+			doc["host"] = map[string]interface{}{"hostname": info.Hostname}
+		}
+	}
+
+	custom := make(map[string]interface{})
+	errorFields := make(map[string]interface{})
+	for k, v := range entry.Fields {
+		switch {
+		case k == "stacktrace":
+			errorFields["stack_trace"] = fmt.Sprint(v)
+		case k == "error" || k == "err":
+			if err, ok := v.(error); ok {
+				errorFields["message"] = errorValue(err)
+				errorFields["type"] = fmt.Sprintf("%T", err)
+			} else {
+				errorFields["message"] = fmt.Sprint(v)
+			}
+		default:
+			if err, ok := v.(error); ok {
+				v = errorValue(err)
+			}
+			custom[k] = v
+		}
+	}
+	if len(errorFields) > 0 {
+		doc["error"] = errorFields
+	}
+
+	if len(custom) > 0 {
+		if f.Namespace == "" {
+			for k, v := range custom {
+				if _, taken := doc[k]; !taken {
+					doc[k] = v
+				}
+			}
+		} else {
+			doc[f.Namespace] = custom
+		}
+	}
+
+	return json.Marshal(doc)
+}
+
+// splitCaller splits an Entry.Caller of the form "file:line"
+func splitCaller(caller string) (string, int) {
+	i := strings.LastIndex(caller, ":")
+	if i <= 0 {
+		return caller, 0
+	}
+	line, err := strconv.Atoi(caller[i+1:])
+	if err != nil {
+		return caller, 0
+	}
+	return caller[:i], line
+}
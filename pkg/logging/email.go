@@ -0,0 +1,298 @@
+package logging
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EmailConfig represents SMTP alert handler configuration
+type EmailConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	To       []string
+
+	// Subject is prefixed with the highest level in the digest and the entry count
+	Subject string
+
+	// MinLevel is the lowest level that is emailed (default ErrorLevel)
+	MinLevel Level
+
+	// DigestInterval is how often pending entries are sent (default 5 minutes)
+	DigestInterval time.Duration
+
+	// MaxEmailsPerHour caps the number of emails sent in any hour (0 = unlimited)
+	MaxEmailsPerHour int
+
+	// MaxEntriesPerDigest caps the entries kept for one digest; older entries
+	// are dropped and counted (default 100)
+	MaxEntriesPerDigest int
+
+	// RequireTLS fails delivery if the server does not offer STARTTLS
+	RequireTLS bool
+	TLSConfig  *tls.Config
+	Timeout    time.Duration
+}
+
+// EmailHandler sends entries at or above a threshold level by email,
+// batched into a digest every DigestInterval
+type EmailHandler struct {
+	config    EmailConfig
+	formatter Formatter
+	pending   []*Entry
+	dropped   int
+	sent      []time.Time
+	mu        sync.Mutex
+	sendMu    sync.Mutex
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewEmailHandler creates a new SMTP alert handler and starts its digest timer
+func NewEmailHandler(config EmailConfig) *EmailHandler {
+	if config.Port == 0 {
+		config.Port = 587
+	}
+	if config.MinLevel.Name == "" {
+		config.MinLevel = ErrorLevel
+	}
+	if config.DigestInterval <= 0 {
+		config.DigestInterval = 5 * time.Minute
+	}
+	if config.MaxEntriesPerDigest <= 0 {
+		config.MaxEntriesPerDigest = 100
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 30 * time.Second
+	}
+	if config.Subject == "" {
+		config.Subject = "Log alert"
+	}
+
+	h := &EmailHandler{
+		config: config,
+		formatter: NewTextFormatter(func(f *TextFormatter) {
+			f.UseColors = false
+		}),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	go h.run()
+	return h
+}
+
+// Handle implements the Handler interface by queueing the entry for the next digest
+func (h *EmailHandler) Handle(entry *Entry) error {
+	if entry.Level.Value < h.config.MinLevel.Value {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.pending = append(h.pending, entry.Clone())
+	if len(h.pending) > h.config.MaxEntriesPerDigest {
+		overflow := len(h.pending) - h.config.MaxEntriesPerDigest
+		h.pending = h.pending[overflow:]
+		h.dropped += overflow
+	}
+	return nil
+}
+
+// SetFormatter sets the formatter used to render entries in the email body
+func (h *EmailHandler) SetFormatter(formatter Formatter) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.formatter = formatter
+}
+
+// Flush sends pending entries immediately, unless the hourly limit is reached
+func (h *EmailHandler) Flush() error {
+	h.sendMu.Lock()
+	defer h.sendMu.Unlock()
+
+	h.mu.Lock()
+	if len(h.pending) == 0 || !h.allowSend(time.Now()) {
+		h.mu.Unlock()
+		return nil
+	}
+	entries, dropped, formatter := h.pending, h.dropped, h.formatter
+	h.pending, h.dropped = nil, 0
+	h.mu.Unlock()
+
+	msg, err := h.buildMessage(entries, dropped, formatter)
+	if err == nil {
+		err = h.send(msg)
+	}
+	if err != nil {
+		// Put the entries back so the next digest retries them
+		h.mu.Lock()
+		h.pending = append(entries, h.pending...)
+		h.dropped += dropped
+		if overflow := len(h.pending) - h.config.MaxEntriesPerDigest; overflow > 0 {
+			h.pending = h.pending[overflow:]
+			h.dropped += overflow
+		}
+		h.mu.Unlock()
+		return fmt.Errorf("failed to send log digest: %w", err)
+	}
+
+	h.mu.Lock()
+	h.sent = append(h.sent, time.Now())
+	h.mu.Unlock()
+	return nil
+}
+
+// Close stops the digest timer and sends any pending entries
+func (h *EmailHandler) Close() error {
+	h.closeOnce.Do(func() {
+		close(h.stop)
+		<-h.done
+	})
+	return h.Flush()
+}
+
+// run sends a digest every DigestInterval until the handler is closed
+func (h *EmailHandler) run() {
+	defer close(h.done)
+
+	ticker := time.NewTicker(h.config.DigestInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			h.Flush()
+		case <-h.stop:
+			return
+		}
+	}
+}
+
+// allowSend reports whether the hourly email limit permits another email.
+// It must be called with h.mu held.
+func (h *EmailHandler) allowSend(now time.Time) bool {
+	if h.config.MaxEmailsPerHour <= 0 {
+		return true
+	}
+
+	cutoff := now.Add(-time.Hour)
+	recent := h.sent[:0]
+	for _, t := range h.sent {
+		if t.After(cutoff) {
+			recent = append(recent, t)
+		}
+	}
+	h.sent = recent
+
+	return len(h.sent) < h.config.MaxEmailsPerHour
+}
+
+// buildMessage renders entries into an RFC 5322 message
+func (h *EmailHandler) buildMessage(entries []*Entry, dropped int, formatter Formatter) ([]byte, error) {
+	highest := entries[0].Level
+	for _, entry := range entries {
+		if entry.Level.Value > highest.Value {
+			highest = entry.Level
+		}
+	}
+
+	hostname, _ := os.Hostname()
+	subject := fmt.Sprintf("[%s] %s (%d entries)", strings.ToUpper(highest.String()), h.config.Subject, len(entries))
+
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "%d log entries at or above %s from %s:\r\n\r\n", len(entries), h.config.MinLevel, hostname)
+	for _, entry := range entries {
+		formatted, err := formatter.Format(entry)
+		if err != nil {
+			return nil, err
+		}
+		body.Write(bytes.ReplaceAll(formatted, []byte("\n"), []byte("\r\n")))
+		body.WriteString("\r\n")
+	}
+	if dropped > 0 {
+		fmt.Fprintf(&body, "\r\n%d older entries were omitted from this digest.\r\n", dropped)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", h.config.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(h.config.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("\r\n")
+	msg.Write(body.Bytes())
+
+	return msg.Bytes(), nil
+}
+
+// send delivers msg over SMTP, upgrading with STARTTLS when available
+func (h *EmailHandler) send(msg []byte) error {
+	addr := net.JoinHostPort(h.config.Host, strconv.Itoa(h.config.Port))
+	conn, err := net.DialTimeout("tcp", addr, h.config.Timeout)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(h.config.Timeout))
+
+	client, err := smtp.NewClient(conn, h.config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		tlsConfig := h.config.TLSConfig
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{ServerName: h.config.Host}
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	} else if h.config.RequireTLS {
+		return fmt.Errorf("SMTP server %s does not support STARTTLS", addr)
+	}
+
+	if h.config.Username != "" {
+		auth := smtp.PlainAuth("", h.config.Username, h.config.Password, h.config.Host)
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(h.config.From); err != nil {
+		return err
+	}
+	for _, to := range h.config.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
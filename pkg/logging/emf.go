@@ -0,0 +1,225 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// MetricsField is the entry field that holds metrics recorded with
+// Logger.Metric
+const MetricsField = "_metrics"
+
+// emfMaxMetrics is the most metric definitions CloudWatch accepts per
+// directive
+const emfMaxMetrics = 100
+
+// Metric is a value recorded with Logger.Metric
+type Metric struct {
+	Name  string
+	Value float64
+	Unit  string
+}
+
+// emfUnits maps lower-cased unit names to the units CloudWatch accepts
+var emfUnits = func() map[string]string {
+	units := []string{
+		"Seconds", "Microseconds", "Milliseconds",
+		"Bytes", "Kilobytes", "Megabytes", "Gigabytes", "Terabytes",
+		"Bits", "Kilobits", "Megabits", "Gigabits", "Terabits",
+		"Percent", "Count",
+		"Bytes/Second", "Kilobytes/Second", "Megabytes/Second", "Gigabytes/Second", "Terabytes/Second",
+		"Bits/Second", "Kilobits/Second", "Megabits/Second", "Gigabits/Second", "Terabits/Second",
+		"Count/Second", "None",
+	}
+	m := make(map[string]string, len(units))
+	for _, u := range units {
+		m[strings.ToLower(u)] = u
+	}
+	return m
+}()
+
+// EMFUnit returns the CloudWatch unit matching unit case-insensitively, or
+// "None" if it is not a CloudWatch unit
+func EMFUnit(unit string) string {
+	if u, ok := emfUnits[strings.ToLower(unit)]; ok {
+		return u
+	}
+	return "None"
+}
+
+// stripMetrics returns entry without the MetricsField, so outputs other
+// than EMFFormatter do not write the raw metrics
+func stripMetrics(entry *Entry) *Entry {
+	if _, ok := entry.Fields[MetricsField]; !ok {
+		return entry
+	}
+	stripped := *entry
+	stripped.Fields = make(Fields, len(entry.Fields)-1)
+	for k, v := range entry.Fields {
+		if k != MetricsField {
+			stripped.Fields[k] = v
+		}
+	}
+	return &stripped
+}
+
+// withMetric returns l with m appended to the metrics in fields
+func withMetric(l Logger, fields Fields, m Metric) Logger {
+	existing, _ := fields[MetricsField].([]Metric)
+	metrics := make([]Metric, len(existing), len(existing)+1)
+	copy(metrics, existing)
+	return l.WithFields(Fields{MetricsField: append(metrics, m)})
+}
+
+// EMFFormatterOption is a functional option for EMFFormatter configuration.
+type EMFFormatterOption func(*EMFFormatter)
+
+// EMFFormatter formats log entries as JSON in the CloudWatch Embedded Metric
+// Format, so metrics are extracted from logs written to stdout by Lambda or
+// the CloudWatch agent.
+//
+// Metrics recorded with Logger.Metric are written as top-level values with
+// an _aws.CloudWatchMetrics directive; a metric recorded more than once is
+// written as an array of values, and a metric overrides a field with the same
+// name. Entries without metrics are written as plain JSON.
+type EMFFormatter struct {
+	// Namespace is the CloudWatch namespace (default from the
+	// AWS_EMF_NAMESPACE environment variable, or "aws-embedded-metrics")
+	Namespace string
+
+	// Dimensions are sets of field names to use as dimensions. Sets whose
+	// fields are missing from an entry are skipped.
+	Dimensions [][]string
+}
+
+// NewEMFFormatter creates a new Embedded Metric Format formatter with options.
+func NewEMFFormatter(opts ...EMFFormatterOption) Formatter {
+	f := &EMFFormatter{
+		Namespace: os.Getenv("AWS_EMF_NAMESPACE"),
+	}
+	if f.Namespace == "" {
+		f.Namespace = "aws-embedded-metrics"
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// WithEMFNamespace sets the CloudWatch namespace.
+func WithEMFNamespace(namespace string) EMFFormatterOption {
+	return func(f *EMFFormatter) {
+		f.Namespace = namespace
+	}
+}
+
+// WithEMFDimensions adds dimension sets, each a list of field names.
+func WithEMFDimensions(dimensions ...[]string) EMFFormatterOption {
+	return func(f *EMFFormatter) {
+		f.Dimensions = append(f.Dimensions, dimensions...)
+	}
+}
+
+// NewEMFHandler creates a handler writing Embedded Metric Format entries to
+// w, or to stdout if w is nil
+func NewEMFHandler(w io.Writer, opts ...EMFFormatterOption) *WriterHandler {
+	if w == nil {
+		w = os.Stdout
+	}
+	return NewWriterHandler(w, WithWriterFormatter(NewEMFFormatter(opts...)))
+}
+
+// Format implements the Formatter interface for Embedded Metric Format output
+func (f *EMFFormatter) Format(entry *Entry) ([]byte, error) {
+	doc := map[string]interface{}{
+		"level":   entry.Level.String(),
+		"message": entry.Message,
+		"time":    entry.Time.Format(time.RFC3339),
+	}
+	if entry.Caller != "" {
+		doc["caller"] = entry.Caller
+	}
+
+	var metrics []Metric
+	for k, v := range entry.Fields {
+		if k == MetricsField {
+			metrics, _ = v.([]Metric)
+			continue
+		}
+		if err, ok := v.(error); ok {
+			v = errorValue(err)
+		}
+		doc[k] = v
+	}
+
+	if len(metrics) == 0 {
+		return json.Marshal(doc)
+	}
+
+	// Group repeated metrics into value arrays, keeping first-seen order
+	var definitions []map[string]string
+	values := make(map[string][]float64)
+	for _, m := range metrics {
+		if _, seen := values[m.Name]; !seen {
+			definitions = append(definitions, map[string]string{"Name": m.Name, "Unit": EMFUnit(m.Unit)})
+		}
+		values[m.Name] = append(values[m.Name], m.Value)
+	}
+	for name, v := range values {
+		if len(v) == 1 {
+			doc[name] = v[0]
+		} else {
+			doc[name] = v
+		}
+	}
+
+	dimensions := make([][]string, 0, len(f.Dimensions))
+	for _, set := range f.Dimensions {
+		if f.dimensionsPresent(doc, set) {
+			dimensions = append(dimensions, set)
+		}
+	}
+
+	var directives []map[string]interface{}
+	for start := 0; start < len(definitions); start += emfMaxMetrics {
+		end := start + emfMaxMetrics
+		if end > len(definitions) {
+			end = len(definitions)
+		}
+		directives = append(directives, map[string]interface{}{
+			"Namespace":  f.Namespace,
+			"Dimensions": dimensions,
+			"Metrics":    definitions[start:end],
+		})
+	}
+
+	doc["_aws"] = map[string]interface{}{
+		"Timestamp":         entry.Time.UnixNano() / int64(time.Millisecond),
+		"CloudWatchMetrics": directives,
+	}
+
+	return json.Marshal(doc)
+}
+
+// dimensionsPresent reports whether every field in set is in doc,
+// converting the values to the strings CloudWatch requires
+func (f *EMFFormatter) dimensionsPresent(doc map[string]interface{}, set []string) bool {
+	if len(set) == 0 {
+		return false
+	}
+	for _, name := range set {
+		if _, ok := doc[name]; !ok {
+			return false
+		}
+	}
+	for _, name := range set {
+		if _, ok := doc[name].(string); !ok {
+			doc[name] = fmt.Sprint(doc[name])
+		}
+	}
+	return true
+}
@@ -0,0 +1,205 @@
+package logging
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"time"
+)
+
+// FileSyncPolicy controls when file handlers call fsync
+type FileSyncPolicy int
+
+const (
+	// FileSyncNever leaves syncing to the operating system
+	FileSyncNever FileSyncPolicy = iota
+	// FileSyncInterval syncs on every flush interval if anything was written
+	FileSyncInterval
+	// FileSyncOnError syncs after every entry at or above the flush level
+	FileSyncOnError
+)
+
+func (p FileSyncPolicy) String() string {
+	switch p {
+	case FileSyncNever:
+		return "never"
+	case FileSyncInterval:
+		return "interval"
+	case FileSyncOnError:
+		return "every-error"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseFileSyncPolicy parses "never", "interval" or "every-error"
+func ParseFileSyncPolicy(s string) (FileSyncPolicy, error) {
+	switch s {
+	case "", "never":
+		return FileSyncNever, nil
+	case "interval":
+		return FileSyncInterval, nil
+	case "every-error", "error":
+		return FileSyncOnError, nil
+	default:
+		return FileSyncNever, fmt.Errorf("invalid fsync policy: %s", s)
+	}
+}
+
+// WithFileBuffer buffers writes in memory (size bytes, default 64KB) instead of
+// issuing one write per entry. The buffer is flushed every flush interval, when
+// an entry at or above the flush level is written, when it is full, and on
+// Flush, rotation, reopen and Close. Buffering is disabled with WithFileLocking,
+// since other processes must see every write before the lock is released.
+func WithFileBuffer(size int) FileOption {
+	return func(o *fileOptions) {
+		if size <= 0 {
+			size = 64 * 1024
+		}
+		o.bufferSize = size
+	}
+}
+
+// WithFileFlushInterval sets how often buffered data is flushed (default 1s)
+func WithFileFlushInterval(interval time.Duration) FileOption {
+	return func(o *fileOptions) {
+		o.flushInterval = interval
+	}
+}
+
+// WithFileFlushLevel sets the level at or above which the buffer is flushed
+// immediately (default ErrorLevel)
+func WithFileFlushLevel(level Level) FileOption {
+	return func(o *fileOptions) {
+		o.flushLevel = level
+	}
+}
+
+// WithFileSync sets the fsync policy (default FileSyncNever)
+func WithFileSync(policy FileSyncPolicy) FileOption {
+	return func(o *fileOptions) {
+		o.syncPolicy = policy
+	}
+}
+
+// fileBuffer applies the buffering and fsync policy of a file handler. It is
+// not safe for concurrent use; handlers call it with their mutex held.
+type fileBuffer struct {
+	opts     fileOptions
+	w        *bufio.Writer
+	file     *os.File
+	unsynced bool
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// newFileBuffer creates the buffer for opts. If buffering or interval syncing
+// is enabled, flush is called every flush interval until close.
+func newFileBuffer(opts fileOptions, flush func() error) *fileBuffer {
+	if opts.locking {
+		opts.bufferSize = 0
+	}
+	if opts.flushInterval <= 0 {
+		opts.flushInterval = time.Second
+	}
+	if opts.flushLevel.Name == "" {
+		opts.flushLevel = ErrorLevel
+	}
+
+	b := &fileBuffer{opts: opts}
+	if opts.bufferSize > 0 {
+		b.w = bufio.NewWriterSize(nil, opts.bufferSize)
+	}
+
+	if b.w != nil || opts.syncPolicy == FileSyncInterval {
+		b.stop = make(chan struct{})
+		b.done = make(chan struct{})
+		go func() {
+			defer close(b.done)
+			ticker := time.NewTicker(opts.flushInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					flush()
+				case <-b.stop:
+					return
+				}
+			}
+		}()
+	}
+
+	return b
+}
+
+// write writes p to f, flushing and syncing according to level. Callers must
+// flush before switching to a different file.
+func (b *fileBuffer) write(f *os.File, p []byte, level Level) error {
+	var err error
+	if b.w == nil {
+		_, err = f.Write(p)
+	} else {
+		if b.file != f {
+			b.w.Reset(f)
+			b.file = f
+		}
+		_, err = b.w.Write(p)
+		if err == nil && level.Value >= b.opts.flushLevel.Value {
+			err = b.w.Flush()
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	b.unsynced = true
+	if b.opts.syncPolicy == FileSyncOnError && level.Value >= b.opts.flushLevel.Value {
+		return b.sync(f)
+	}
+	return nil
+}
+
+// flush writes buffered data to the file
+func (b *fileBuffer) flush() error {
+	if b.w == nil || b.file == nil {
+		return nil
+	}
+	return b.w.Flush()
+}
+
+// buffered returns the number of bytes waiting to be written
+func (b *fileBuffer) buffered() int {
+	if b.w == nil {
+		return 0
+	}
+	return b.w.Buffered()
+}
+
+// flushAndSync flushes and, with FileSyncInterval, syncs f
+func (b *fileBuffer) flushAndSync(f *os.File) error {
+	if err := b.flush(); err != nil {
+		return err
+	}
+	if b.opts.syncPolicy == FileSyncInterval {
+		return b.sync(f)
+	}
+	return nil
+}
+
+// sync calls fsync on f if anything was written since the last sync
+func (b *fileBuffer) sync(f *os.File) error {
+	if !b.unsynced || f == nil {
+		return nil
+	}
+	b.unsynced = false
+	return f.Sync()
+}
+
+// close stops the flush timer; the caller flushes afterwards
+func (b *fileBuffer) close() {
+	if b.stop != nil {
+		close(b.stop)
+		<-b.done
+		b.stop = nil
+	}
+}
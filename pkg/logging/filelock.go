@@ -0,0 +1,161 @@
+package logging
+
+import (
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// FileOption is a functional option for FileHandler and RotatingFileHandler
+type FileOption func(*fileOptions)
+
+// fileOptions holds the multi-process and buffering settings of a file handler
+type fileOptions struct {
+	locking       bool
+	detectRotate  bool
+	reopenSignals []os.Signal
+
+	bufferSize    int
+	flushInterval time.Duration
+	flushLevel    Level
+	syncPolicy    FileSyncPolicy
+}
+
+// applyFileOptions returns the settings described by opts
+func applyFileOptions(opts []FileOption) fileOptions {
+	var o fileOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithFileLocking takes an advisory lock (flock) on "<filename>.lock" around
+// every write and rotation, so several processes can share one log file.
+// It also enables WithFileReopenOnRotate. Locking is a no-op on platforms
+// without flock.
+func WithFileLocking() FileOption {
+	return func(o *fileOptions) {
+		o.locking = true
+		o.detectRotate = true
+	}
+}
+
+// WithFileReopenOnRotate checks before every write whether the log file was
+// renamed, removed or truncated by another process and reopens it if so.
+func WithFileReopenOnRotate() FileOption {
+	return func(o *fileOptions) {
+		o.detectRotate = true
+	}
+}
+
+// WithFileReopenSignal reopens the log file whenever one of sigs is received.
+func WithFileReopenSignal(sigs ...os.Signal) FileOption {
+	return func(o *fileOptions) {
+		o.reopenSignals = append(o.reopenSignals, sigs...)
+	}
+}
+
+// WithFileReopenOnSIGHUP reopens the log file on SIGHUP, as expected by
+// logrotate "create" and "copytruncate" setups.
+func WithFileReopenOnSIGHUP() FileOption {
+	return WithFileReopenSignal(syscall.SIGHUP)
+}
+
+// fileGuard coordinates access to a log file shared with other processes
+type fileGuard struct {
+	opts     fileOptions
+	lockFile *os.File
+	signals  chan os.Signal
+	done     chan struct{}
+}
+
+// newFileGuard creates a guard for filename; reopen is called on every reopen signal
+func newFileGuard(filename string, opts fileOptions, reopen func() error) (*fileGuard, error) {
+	g := &fileGuard{opts: opts}
+
+	if g.opts.locking {
+		lockFile, err := os.OpenFile(filename+".lock", os.O_CREATE|os.O_RDWR, 0666)
+		if err != nil {
+			return nil, err
+		}
+		g.lockFile = lockFile
+	}
+
+	if len(g.opts.reopenSignals) > 0 {
+		g.signals = make(chan os.Signal, 1)
+		g.done = make(chan struct{})
+		signal.Notify(g.signals, g.opts.reopenSignals...)
+		go func() {
+			for {
+				select {
+				case <-g.signals:
+					reopen()
+				case <-g.done:
+					return
+				}
+			}
+		}()
+	}
+
+	return g, nil
+}
+
+// lock acquires the inter-process lock if locking is enabled
+func (g *fileGuard) lock() error {
+	if g.lockFile == nil {
+		return nil
+	}
+	return flockExclusive(g.lockFile)
+}
+
+// unlock releases the inter-process lock
+func (g *fileGuard) unlock() {
+	if g.lockFile != nil {
+		flockRelease(g.lockFile)
+	}
+}
+
+// changed reports whether filename no longer refers to the open file f, or
+// the file was truncated below the position of our last write
+func (g *fileGuard) changed(filename string, f *os.File) bool {
+	if !g.opts.detectRotate || f == nil {
+		return false
+	}
+	pathInfo, err := os.Stat(filename)
+	if err != nil {
+		return true
+	}
+	fileInfo, err := f.Stat()
+	if err != nil {
+		return true
+	}
+	if !os.SameFile(pathInfo, fileInfo) {
+		return true
+	}
+	pos, err := f.Seek(0, io.SeekCurrent)
+	return err == nil && fileInfo.Size() < pos
+}
+
+// stopSignals stops signal handling. It must be called without holding the
+// handler's mutex, since the signal goroutine takes it to reopen the file.
+func (g *fileGuard) stopSignals() {
+	if g.signals != nil {
+		signal.Stop(g.signals)
+		close(g.done)
+		g.signals = nil
+	}
+}
+
+// closeLock releases the lock file. It must be called with the handler's
+// mutex held, like lock and unlock.
+func (g *fileGuard) closeLock() error {
+	if g.lockFile != nil {
+		err := g.lockFile.Close()
+		g.lockFile = nil
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package logging
+
+import "os"
+
+// flockExclusive is a no-op on platforms without flock
+func flockExclusive(f *os.File) error {
+	return nil
+}
+
+// flockRelease is a no-op on platforms without flock
+func flockRelease(f *os.File) error {
+	return nil
+}
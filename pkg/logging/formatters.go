@@ -1,9 +1,11 @@
 package logging
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -120,6 +122,7 @@ func WithTextFormatterFieldMasking(maskFields []string, mask string) TextFormatt
 
 // Format implements the Formatter interface for text output
 func (f *TextFormatter) Format(entry *Entry) ([]byte, error) {
+	entry = stripMetrics(entry)
 	var parts []string
 
 	// Add timestamp
@@ -148,9 +151,19 @@ func (f *TextFormatter) Format(entry *Entry) ([]byte, error) {
 	// Add message
 	parts = append(parts, entry.Message)
 
-	// Add fields
-	if len(entry.Fields) > 0 {
-		fieldStr := f.formatFields(entry.Fields)
+	// Add fields; a captured stack trace goes on indented lines below
+	fields := entry.Fields
+	stack, _ := fields[StacktraceField].(StackTrace)
+	if stack != nil {
+		fields = make(Fields, len(entry.Fields)-1)
+		for k, v := range entry.Fields {
+			if k != StacktraceField {
+				fields[k] = v
+			}
+		}
+	}
+	if len(fields) > 0 {
+		fieldStr := f.formatFields(fields)
 		parts = append(parts, fieldStr)
 	}
 
@@ -160,6 +173,9 @@ func (f *TextFormatter) Format(entry *Entry) ([]byte, error) {
 	}
 
 	result := strings.Join(parts, " ")
+	if len(stack) > 0 {
+		result += "\n    " + strings.ReplaceAll(stack.String(), "\n", "\n    ")
+	}
 	return []byte(result), nil
 }
 
@@ -226,43 +242,270 @@ func (f *TextFormatter) isMaskedField(key string) bool {
 	return false
 }
 
+// Timestamp formats for JSONFormatter besides Go time layouts
+const (
+	JSONTimeEpochSeconds = "epoch"
+	JSONTimeEpochMillis  = "epoch_millis"
+	JSONTimeEpochNanos   = "epoch_nanos"
+)
+
+// JSONCollisionPolicy decides what happens to fields whose names clash with
+// the time, level, message or caller keys
+type JSONCollisionPolicy int
+
+const (
+	// JSONCollisionPrefix writes clashing fields as "fields.<name>"
+	JSONCollisionPrefix JSONCollisionPolicy = iota
+	// JSONCollisionNest writes clashing fields in a "fields" object
+	JSONCollisionNest
+	// JSONCollisionOverwrite lets clashing fields replace the built-in keys
+	JSONCollisionOverwrite
+)
+
+// JSONFormatterOption is a functional option for JSONFormatter configuration.
+type JSONFormatterOption func(*JSONFormatter)
+
 // JSONFormatter formats log entries as JSON
+//
+// Keys are written in a stable order: time, level, message and caller, then
+// fields in FieldOrder, then the remaining fields sorted by name. Common
+// value types are encoded without reflection.
 type JSONFormatter struct {
 	PrettyPrint bool
+
+	// Key names (default "time", "level", "message" and "caller")
+	TimeKey    string
+	LevelKey   string
+	MessageKey string
+	CallerKey  string
+
+	// TimestampFormat is a Go time layout or one of the JSONTimeEpoch
+	// constants (default time.RFC3339)
+	TimestampFormat  string
+	DisableTimestamp bool
+
+	Collision  JSONCollisionPolicy
+	FieldOrder []string
+	EscapeHTML bool
+}
+
+// NewJSONFormatter creates a new JSON formatter with options.
+func NewJSONFormatter(opts ...JSONFormatterOption) Formatter {
+	f := &JSONFormatter{
+		PrettyPrint:     false,
+		TimeKey:         "time",
+		LevelKey:        "level",
+		MessageKey:      "message",
+		CallerKey:       "caller",
+		TimestampFormat: time.RFC3339,
+		EscapeHTML:      true,
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// WithJSONKeys renames the time, level, message and caller keys. Empty
+// names keep the current key.
+func WithJSONKeys(timeKey, levelKey, messageKey, callerKey string) JSONFormatterOption {
+	return func(f *JSONFormatter) {
+		if timeKey != "" {
+			f.TimeKey = timeKey
+		}
+		if levelKey != "" {
+			f.LevelKey = levelKey
+		}
+		if messageKey != "" {
+			f.MessageKey = messageKey
+		}
+		if callerKey != "" {
+			f.CallerKey = callerKey
+		}
+	}
+}
+
+// WithJSONTimestampFormat sets a Go time layout or a JSONTimeEpoch constant.
+func WithJSONTimestampFormat(format string) JSONFormatterOption {
+	return func(f *JSONFormatter) {
+		f.TimestampFormat = format
+	}
+}
+
+// WithJSONDisableTimestamp leaves the timestamp out.
+func WithJSONDisableTimestamp() JSONFormatterOption {
+	return func(f *JSONFormatter) {
+		f.DisableTimestamp = true
+	}
+}
+
+// WithJSONCollisionPolicy sets how fields clashing with built-in keys are written.
+func WithJSONCollisionPolicy(policy JSONCollisionPolicy) JSONFormatterOption {
+	return func(f *JSONFormatter) {
+		f.Collision = policy
+	}
+}
+
+// WithJSONFieldOrder sets fields written before the remaining sorted fields.
+func WithJSONFieldOrder(order []string) JSONFormatterOption {
+	return func(f *JSONFormatter) {
+		f.FieldOrder = order
+	}
 }
 
-// NewJSONFormatter creates a new JSON formatter
-func NewJSONFormatter() Formatter {
-	return &JSONFormatter{
-		PrettyPrint: false,
+// WithJSONEscapeHTML controls escaping of <, > and & in strings.
+func WithJSONEscapeHTML(escape bool) JSONFormatterOption {
+	return func(f *JSONFormatter) {
+		f.EscapeHTML = escape
+	}
+}
+
+// WithJSONPrettyPrint enables indented output.
+func WithJSONPrettyPrint(pretty bool) JSONFormatterOption {
+	return func(f *JSONFormatter) {
+		f.PrettyPrint = pretty
 	}
 }
 
 // Format implements the Formatter interface for JSON output
 func (f *JSONFormatter) Format(entry *Entry) ([]byte, error) {
-	data := map[string]interface{}{
-		"level":   entry.Level.String(),
-		"message": entry.Message,
-		"time":    entry.Time.Format(time.RFC3339),
+	entry = stripMetrics(entry)
+	timeKey := jsonKeyOr(f.TimeKey, "time")
+	levelKey := jsonKeyOr(f.LevelKey, "level")
+	messageKey := jsonKeyOr(f.MessageKey, "message")
+	callerKey := jsonKeyOr(f.CallerKey, "caller")
+	reserved := func(k string) bool {
+		return k == timeKey || k == levelKey || k == messageKey || k == callerKey
 	}
 
-	// Add fields
-	if len(entry.Fields) > 0 {
-		for k, v := range entry.Fields {
-			data[k] = v
+	keys := f.fieldKeys(entry.Fields)
+	var nested Fields
+	if f.Collision == JSONCollisionNest {
+		for _, k := range keys {
+			if reserved(k) || k == "fields" {
+				if nested == nil {
+					nested = make(Fields)
+				}
+				nested[k] = entry.Fields[k]
+			}
 		}
 	}
+	overwritten := func(k string) bool {
+		if f.Collision != JSONCollisionOverwrite {
+			return false
+		}
+		_, ok := entry.Fields[k]
+		return ok
+	}
 
-	// Add caller if available
-	if entry.Caller != "" {
-		data["caller"] = entry.Caller
+	buf := make([]byte, 0, 256)
+	buf = append(buf, '{')
+	n := 0
+	appendKey := func(k string) {
+		if n > 0 {
+			buf = append(buf, ',')
+		}
+		n++
+		buf = appendJSONString(buf, k, f.EscapeHTML)
+		buf = append(buf, ':')
+	}
+
+	if !f.DisableTimestamp && !overwritten(timeKey) {
+		appendKey(timeKey)
+		buf = f.appendTime(buf, entry.Time)
+	}
+	if !overwritten(levelKey) {
+		appendKey(levelKey)
+		buf = appendJSONString(buf, entry.Level.String(), f.EscapeHTML)
+	}
+	if !overwritten(messageKey) {
+		appendKey(messageKey)
+		buf = appendJSONString(buf, entry.Message, f.EscapeHTML)
+	}
+	if entry.Caller != "" && !overwritten(callerKey) {
+		appendKey(callerKey)
+		buf = appendJSONString(buf, entry.Caller, f.EscapeHTML)
+	}
+
+	var err error
+	for _, k := range keys {
+		if nested != nil {
+			if _, ok := nested[k]; ok {
+				continue
+			}
+		}
+		name := k
+		if f.Collision == JSONCollisionPrefix && reserved(k) {
+			name = "fields." + k
+		}
+		appendKey(name)
+		if buf, err = appendJSONValue(buf, entry.Fields[k], f.EscapeHTML); err != nil {
+			return nil, err
+		}
+	}
+	if nested != nil {
+		appendKey("fields")
+		if buf, err = appendJSONObject(buf, nested, f.EscapeHTML); err != nil {
+			return nil, err
+		}
 	}
+	buf = append(buf, '}')
 
 	if f.PrettyPrint {
-		return json.MarshalIndent(data, "", "  ")
+		var out bytes.Buffer
+		if err := json.Indent(&out, buf, "", "  "); err != nil {
+			return nil, err
+		}
+		return out.Bytes(), nil
+	}
+	return buf, nil
+}
+
+// fieldKeys returns the field names in FieldOrder first, then sorted
+func (f *JSONFormatter) fieldKeys(fields Fields) []string {
+	keys := make([]string, 0, len(fields))
+	seen := make(map[string]bool, len(f.FieldOrder))
+	for _, k := range f.FieldOrder {
+		if _, ok := fields[k]; ok && !seen[k] {
+			keys = append(keys, k)
+			seen[k] = true
+		}
+	}
+	ordered := len(keys)
+	for k := range fields {
+		if !seen[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys[ordered:])
+	return keys
+}
+
+// appendTime appends t in the configured timestamp format
+func (f *JSONFormatter) appendTime(buf []byte, t time.Time) []byte {
+	switch f.TimestampFormat {
+	case JSONTimeEpochSeconds:
+		return strconv.AppendInt(buf, t.Unix(), 10)
+	case JSONTimeEpochMillis:
+		return strconv.AppendInt(buf, t.UnixNano()/int64(time.Millisecond), 10)
+	case JSONTimeEpochNanos:
+		return strconv.AppendInt(buf, t.UnixNano(), 10)
+	}
+	layout := f.TimestampFormat
+	if layout == "" {
+		layout = time.RFC3339
 	}
+	buf = append(buf, '"')
+	buf = t.AppendFormat(buf, layout)
+	return append(buf, '"')
+}
 
-	return json.Marshal(data)
+// jsonKeyOr returns key, or def if key is empty
+func jsonKeyOr(key, def string) string {
+	if key == "" {
+		return def
+	}
+	return key
 }
 
 // SetPrettyPrint enables or disables pretty printing for JSON
@@ -0,0 +1,273 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// GCPFormatterOption is a functional option for GCPFormatter configuration.
+type GCPFormatterOption func(*GCPFormatter)
+
+// GCPFormatter formats log entries in the Google Cloud Logging structured
+// JSON layout understood by Cloud Run, GKE and Cloud Functions.
+//
+// Trace and span IDs from the entry's span or trace context are written as
+// logging.googleapis.com/trace and spanId, so entries are correlated with
+// Cloud Trace. Request fields listed in HTTPRequestFields (none unless
+// configured) become the httpRequest object, fields listed in LabelFields
+// become labels, and a stacktrace field is appended to the message for
+// Error Reporting.
+type GCPFormatter struct {
+	// ProjectID is used to build trace resource names (default from the
+	// GOOGLE_CLOUD_PROJECT or GCP_PROJECT environment variable)
+	ProjectID string
+
+	// Labels are added to every entry
+	Labels map[string]string
+
+	// LabelFields are fields moved into labels
+	LabelFields []string
+
+	// HTTPRequestFields maps field names to httpRequest properties. Values
+	// of the wrong type, such as a non-numeric status, stay in the payload.
+	HTTPRequestFields map[string]string
+}
+
+// DefaultGCPHTTPRequestFields maps common request field names to
+// httpRequest properties. It is not applied by default since names such as
+// status and path are often used for other things; enable it with
+// WithGCPHTTPRequestFields(DefaultGCPHTTPRequestFields).
+var DefaultGCPHTTPRequestFields = map[string]string{
+	"method":        "requestMethod",
+	"url":           "requestUrl",
+	"path":          "requestUrl",
+	"status":        "status",
+	"user_agent":    "userAgent",
+	"remote_ip":     "remoteIp",
+	"remote_addr":   "remoteIp",
+	"referer":       "referer",
+	"protocol":      "protocol",
+	"request_size":  "requestSize",
+	"response_size": "responseSize",
+	"latency":       "latency",
+	"duration":      "latency",
+	"duration_ms":   "latency",
+}
+
+// gcpReservedKeys are the top-level keys written by the formatter itself
+var gcpReservedKeys = map[string]bool{
+	"severity": true, "message": true, "time": true, "httpRequest": true,
+}
+
+// NewGCPFormatter creates a new Google Cloud Logging formatter with options.
+func NewGCPFormatter(opts ...GCPFormatterOption) Formatter {
+	f := &GCPFormatter{
+		ProjectID: os.Getenv("GOOGLE_CLOUD_PROJECT"),
+	}
+	if f.ProjectID == "" {
+		f.ProjectID = os.Getenv("GCP_PROJECT")
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// WithGCPProjectID sets the project used in trace resource names.
+func WithGCPProjectID(projectID string) GCPFormatterOption {
+	return func(f *GCPFormatter) {
+		f.ProjectID = projectID
+	}
+}
+
+// WithGCPLabels adds labels to every entry.
+func WithGCPLabels(labels map[string]string) GCPFormatterOption {
+	return func(f *GCPFormatter) {
+		f.Labels = labels
+	}
+}
+
+// WithGCPLabelFields moves the given fields into labels.
+func WithGCPLabelFields(fields ...string) GCPFormatterOption {
+	return func(f *GCPFormatter) {
+		f.LabelFields = fields
+	}
+}
+
+// WithGCPHTTPRequestFields sets the field to httpRequest mapping, e.g.
+// DefaultGCPHTTPRequestFields.
+func WithGCPHTTPRequestFields(mapping map[string]string) GCPFormatterOption {
+	return func(f *GCPFormatter) {
+		f.HTTPRequestFields = mapping
+	}
+}
+
+// GCPSeverity maps a level to a Cloud Logging severity. Custom levels
+// between the built-in ones map to the nearest severity below them, with
+// levels between info and warn mapping to NOTICE.
+func GCPSeverity(level Level) string {
+	switch {
+	case level.Value > PanicLevel.Value:
+		return "EMERGENCY"
+	case level.Value >= PanicLevel.Value:
+		return "ALERT"
+	case level.Value >= FatalLevel.Value:
+		return "CRITICAL"
+	case level.Value >= ErrorLevel.Value:
+		return "ERROR"
+	case level.Value >= WarnLevel.Value:
+		return "WARNING"
+	case level.Value > InfoLevel.Value:
+		return "NOTICE"
+	case level.Value >= InfoLevel.Value:
+		return "INFO"
+	case level.Value >= DebugLevel.Value:
+		return "DEBUG"
+	default:
+		return "DEFAULT"
+	}
+}
+
+// Format implements the Formatter interface for Cloud Logging output
+func (f *GCPFormatter) Format(entry *Entry) ([]byte, error) {
+	entry = stripMetrics(entry)
+	doc := map[string]interface{}{
+		"severity": GCPSeverity(entry.Level),
+		"message":  entry.Message,
+		"time":     entry.Time.UTC().Format(time.RFC3339Nano),
+	}
+
+	if traceID, spanID := entryTraceIDs(entry); traceID != "" {
+		if f.ProjectID != "" {
+			doc["logging.googleapis.com/trace"] = "projects/" + f.ProjectID + "/traces/" + traceID
+		} else {
+			doc["logging.googleapis.com/trace"] = traceID
+		}
+		if spanID != "" {
+			doc["logging.googleapis.com/spanId"] = spanID
+		}
+	}
+
+	if file, line := splitCaller(entry.Caller); file != "" {
+		location := map[string]string{"file": file}
+		if line > 0 {
+			location["line"] = strconv.Itoa(line)
+		}
+		doc["logging.googleapis.com/sourceLocation"] = location
+	}
+
+	labels := make(map[string]string, len(f.Labels))
+	for k, v := range f.Labels {
+		labels[k] = v
+	}
+	isLabel := make(map[string]bool, len(f.LabelFields))
+	for _, k := range f.LabelFields {
+		isLabel[k] = true
+	}
+
+	httpRequest := make(map[string]interface{})
+	for k, v := range entry.Fields {
+		switch {
+		case k == "stacktrace":
+			if st, ok := v.(StackTrace); ok {
+				v = st.runtimeFormat()
+			}
+			doc["message"] = fmt.Sprintf("%s\n%v", entry.Message, v)
+		case isLabel[k]:
+			labels[k] = fmt.Sprint(v)
+		case f.HTTPRequestFields[k] != "" && gcpHTTPRequestValid(f.HTTPRequestFields[k], v):
+			property := f.HTTPRequestFields[k]
+			httpRequest[property] = gcpHTTPRequestValue(property, k, v)
+		default:
+			if err, ok := v.(error); ok {
+				v = errorValue(err)
+			}
+			if gcpReservedKeys[k] {
+				k = "fields." + k
+			}
+			doc[k] = v
+		}
+	}
+
+	if len(labels) > 0 {
+		doc["logging.googleapis.com/labels"] = labels
+	}
+	if len(httpRequest) > 0 {
+		doc["httpRequest"] = httpRequest
+	}
+
+	return json.Marshal(doc)
+}
+
+// gcpHTTPRequestValid reports whether value can be written as the
+// httpRequest property: latency as a duration or number of seconds (of
+// milliseconds for duration_ms) and status as an integer
+func gcpHTTPRequestValid(property string, value interface{}) bool {
+	switch property {
+	case "latency":
+		if _, ok := value.(time.Duration); ok {
+			return true
+		}
+		_, ok := gcpNumber(value)
+		return ok
+	case "status":
+		n, ok := gcpNumber(value)
+		return ok && n == float64(int64(n))
+	}
+	return true
+}
+
+// gcpHTTPRequestValue converts a field value to the type the httpRequest
+// property expects: latency as a duration string like "0.25s", sizes as
+// decimal strings and status as a number
+func gcpHTTPRequestValue(property, field string, value interface{}) interface{} {
+	switch property {
+	case "latency":
+		if d, ok := value.(time.Duration); ok {
+			return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s"
+		}
+		n, _ := gcpNumber(value)
+		if field == "duration_ms" {
+			n /= 1000
+		}
+		return strconv.FormatFloat(n, 'f', -1, 64) + "s"
+	case "status":
+		n, _ := gcpNumber(value)
+		return int64(n)
+	}
+	return fmt.Sprint(value)
+}
+
+// gcpNumber converts integer and floating-point values to float64
+func gcpNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
@@ -0,0 +1,361 @@
+package logging
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// GELFFormatterOption is a functional option for GELFFormatter configuration.
+type GELFFormatterOption func(*GELFFormatter)
+
+// GELFFormatter formats log entries as GELF 1.1 JSON for Graylog.
+//
+// The message becomes short_message; a "stacktrace" field is moved into
+// full_message. All other fields are written as additional fields with a
+// "_" prefix, nested maps are flattened with "_" and values that are not
+// numbers are written as strings.
+type GELFFormatter struct {
+	// Host is the source host (default os.Hostname)
+	Host string
+
+	// Fields are added to every message as additional fields
+	Fields Fields
+}
+
+// NewGELFFormatter creates a new GELF formatter with options.
+func NewGELFFormatter(opts ...GELFFormatterOption) Formatter {
+	f := &GELFFormatter{}
+	f.Host, _ = os.Hostname()
+	for _, opt := range opts {
+		opt(f)
+	}
+	if f.Host == "" {
+		f.Host = "unknown"
+	}
+	return f
+}
+
+// WithGELFHost sets the host reported in every message.
+func WithGELFHost(host string) GELFFormatterOption {
+	return func(f *GELFFormatter) {
+		f.Host = host
+	}
+}
+
+// WithGELFFields adds static additional fields, e.g. facility or environment.
+func WithGELFFields(fields Fields) GELFFormatterOption {
+	return func(f *GELFFormatter) {
+		f.Fields = fields
+	}
+}
+
+// GELFLevel maps a level to its syslog severity number
+func GELFLevel(level Level) int {
+	switch {
+	case level.Value >= PanicLevel.Value:
+		return 1 // alert
+	case level.Value >= FatalLevel.Value:
+		return 2 // critical
+	case level.Value >= ErrorLevel.Value:
+		return 3 // error
+	case level.Value >= WarnLevel.Value:
+		return 4 // warning
+	case level.Value >= InfoLevel.Value:
+		return 6 // informational
+	default:
+		return 7 // debug
+	}
+}
+
+// gelfFieldName matches the additional field names Graylog accepts
+var gelfFieldName = regexp.MustCompile(`[^\w.\-]`)
+
+// Format implements the Formatter interface for GELF output
+func (f *GELFFormatter) Format(entry *Entry) ([]byte, error) {
+	entry = stripMetrics(entry)
+	msg := map[string]interface{}{
+		"version":       "1.1",
+		"host":          f.Host,
+		"short_message": entry.Message,
+		"timestamp":     float64(entry.Time.UnixNano()/int64(time.Millisecond)) / 1000,
+		"level":         GELFLevel(entry.Level),
+		"_level_name":   entry.Level.String(),
+	}
+	if entry.Message == "" {
+		msg["short_message"] = "-"
+	}
+
+	for k, v := range f.Fields {
+		addGELFField(msg, k, v)
+	}
+	for k, v := range entry.Fields {
+		if k == "stacktrace" {
+			msg["full_message"] = fmt.Sprintf("%s\n%v", entry.Message, v)
+			continue
+		}
+		addGELFField(msg, k, v)
+	}
+
+	if file, line := splitCaller(entry.Caller); file != "" {
+		msg["_file"] = file
+		if line > 0 {
+			msg["_line"] = line
+		}
+	}
+
+	return json.Marshal(msg)
+}
+
+// addGELFField adds an additional field, flattening maps and converting
+// values GELF does not allow to strings
+func addGELFField(msg map[string]interface{}, key string, value interface{}) {
+	key = gelfFieldName.ReplaceAllString(key, "_")
+	if key == "id" {
+		key = "id_" // _id is reserved by Graylog
+	}
+
+	switch v := value.(type) {
+	case Fields:
+		for k, nested := range v {
+			addGELFField(msg, key+"_"+k, nested)
+		}
+	case map[string]interface{}:
+		for k, nested := range v {
+			addGELFField(msg, key+"_"+k, nested)
+		}
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		msg["_"+key] = v
+	case string:
+		msg["_"+key] = v
+	case time.Time:
+		msg["_"+key] = v.Format(time.RFC3339Nano)
+	case error, fmt.Stringer:
+		msg["_"+key] = fieldString(v)
+	default:
+		msg["_"+key] = fmt.Sprint(v)
+	}
+}
+
+// GELFCompression selects the compression of GELF UDP messages
+type GELFCompression int
+
+const (
+	GELFCompressGzip GELFCompression = iota
+	GELFCompressZlib
+	GELFCompressNone
+)
+
+// gelfChunkMagic starts every chunk of a chunked GELF UDP message
+var gelfChunkMagic = []byte{0x1e, 0x0f}
+
+// gelfMaxChunks is the most chunks Graylog accepts for one message
+const gelfMaxChunks = 128
+
+// GELFConfig represents GELF handler configuration
+type GELFConfig struct {
+	// Address is the Graylog input, e.g. "graylog:12201"
+	Address string
+
+	// Protocol is "udp" (default) or "tcp"
+	Protocol string
+
+	// Compression applies to UDP only; TCP messages are never compressed
+	Compression GELFCompression
+
+	// ChunkSize is the maximum UDP datagram size (default 1420)
+	ChunkSize int
+
+	// Formatter options, e.g. WithGELFHost or WithGELFFields
+	FormatterOptions []GELFFormatterOption
+
+	Timeout time.Duration
+}
+
+// GELFHandler sends GELF messages to Graylog over UDP or TCP
+type GELFHandler struct {
+	config    GELFConfig
+	formatter Formatter
+	conn      net.Conn
+	mu        sync.Mutex
+}
+
+// NewGELFHandler creates a new GELF handler and connects to Graylog
+func NewGELFHandler(config GELFConfig) (*GELFHandler, error) {
+	if config.Protocol == "" {
+		config.Protocol = "udp"
+	}
+	if config.Protocol != "udp" && config.Protocol != "tcp" {
+		return nil, fmt.Errorf("unsupported GELF protocol: %s", config.Protocol)
+	}
+	if config.ChunkSize <= len(gelfChunkMagic)+10 {
+		config.ChunkSize = 1420
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+
+	h := &GELFHandler{
+		config:    config,
+		formatter: NewGELFFormatter(config.FormatterOptions...),
+	}
+	if err := h.connect(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Handle implements the Handler interface for GELF output
+func (h *GELFHandler) Handle(entry *Entry) error {
+	data, err := h.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.config.Protocol == "tcp" {
+		return h.writeTCP(append(data, 0))
+	}
+	return h.writeUDP(data)
+}
+
+// Close closes the connection
+func (h *GELFHandler) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.conn == nil {
+		return nil
+	}
+	err := h.conn.Close()
+	h.conn = nil
+	return err
+}
+
+// connect dials the Graylog input
+func (h *GELFHandler) connect() error {
+	conn, err := net.DialTimeout(h.config.Protocol, h.config.Address, h.config.Timeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to GELF input %s: %w", h.config.Address, err)
+	}
+	h.conn = conn
+	return nil
+}
+
+// writeTCP writes a null-terminated message, reconnecting once on failure
+func (h *GELFHandler) writeTCP(data []byte) error {
+	for attempt := 0; ; attempt++ {
+		if h.conn == nil {
+			if err := h.connect(); err != nil {
+				return err
+			}
+		}
+
+		h.conn.SetWriteDeadline(time.Now().Add(h.config.Timeout))
+		_, err := h.conn.Write(data)
+		if err == nil {
+			return nil
+		}
+
+		h.conn.Close()
+		h.conn = nil
+		if attempt > 0 {
+			return err
+		}
+	}
+}
+
+// writeUDP compresses data and sends it in one datagram or in chunks
+func (h *GELFHandler) writeUDP(data []byte) error {
+	if h.conn == nil {
+		if err := h.connect(); err != nil {
+			return err
+		}
+	}
+
+	data, err := compressGELF(data, h.config.Compression)
+	if err != nil {
+		return err
+	}
+
+	if len(data) <= h.config.ChunkSize {
+		_, err := h.conn.Write(data)
+		return err
+	}
+
+	for _, chunk := range chunkGELF(data, h.config.ChunkSize) {
+		if chunk == nil {
+			return fmt.Errorf("GELF message of %d bytes exceeds %d chunks", len(data), gelfMaxChunks)
+		}
+		if _, err := h.conn.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compressGELF compresses data with the given method
+func compressGELF(data []byte, compression GELFCompression) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+
+	switch compression {
+	case GELFCompressNone:
+		return data, nil
+	case GELFCompressZlib:
+		w = zlib.NewWriter(&buf)
+	default:
+		w = gzip.NewWriter(&buf)
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// chunkGELF splits data into GELF chunks of at most size bytes each. It
+// returns a single nil chunk if more than gelfMaxChunks would be needed.
+func chunkGELF(data []byte, size int) [][]byte {
+	const headerSize = 12 // magic (2) + message id (8) + sequence (1) + count (1)
+	payload := size - headerSize
+	count := (len(data) + payload - 1) / payload
+	if count > gelfMaxChunks {
+		return [][]byte{nil}
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		copy(id, strconv.FormatInt(time.Now().UnixNano(), 36))
+	}
+
+	chunks := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * payload
+		if end > len(data) {
+			end = len(data)
+		}
+
+		chunk := make([]byte, 0, headerSize+end-i*payload)
+		chunk = append(chunk, gelfChunkMagic...)
+		chunk = append(chunk, id...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunk = append(chunk, data[i*payload:end]...)
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
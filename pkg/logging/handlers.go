@@ -1,26 +1,81 @@
 package logging
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/mattn/go-isatty"
 )
 
 // ConsoleHandler handles logging to console
+//
+// By default every entry is written to stdout. Use WithConsoleSplitLevel to
+// send entries at or above a level to stderr instead. Colors are disabled
+// per stream when it is not a terminal or NO_COLOR is set.
 type ConsoleHandler struct {
-	formatter Formatter
-	mu        sync.Mutex
+	formatter  Formatter
+	plain      plainFormatterCache
+	stdout     io.Writer
+	stderr     io.Writer
+	split      bool
+	splitLevel Level
+	outColors  bool
+	errColors  bool
+	mu         sync.Mutex
+}
+
+// ConsoleHandlerOption is a functional option for ConsoleHandler configuration.
+type ConsoleHandlerOption func(*ConsoleHandler)
+
+// WithConsoleSplitLevel sends entries at or above level to stderr and the rest to stdout.
+func WithConsoleSplitLevel(level Level) ConsoleHandlerOption {
+	return func(h *ConsoleHandler) {
+		h.split = true
+		h.splitLevel = level
+	}
+}
+
+// WithConsoleWriters replaces the stdout and stderr streams of the console handler.
+func WithConsoleWriters(stdout, stderr io.Writer) ConsoleHandlerOption {
+	return func(h *ConsoleHandler) {
+		h.stdout = stdout
+		h.stderr = stderr
+	}
+}
+
+// WithConsoleFormatter sets the formatter for the console handler.
+func WithConsoleFormatter(formatter Formatter) ConsoleHandlerOption {
+	return func(h *ConsoleHandler) {
+		h.formatter = formatter
+	}
 }
 
 // NewConsoleHandler creates a new console handler
-func NewConsoleHandler() Handler {
-	return &ConsoleHandler{
-		formatter: NewTextFormatter(),
+func NewConsoleHandler(opts ...ConsoleHandlerOption) Handler {
+	h := &ConsoleHandler{
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
+	h.outColors = colorsEnabled(h.stdout)
+	h.errColors = colorsEnabled(h.stderr)
+	return h
+}
+
+// NewSplitConsoleHandler creates a console handler that writes warnings and
+// above to stderr and everything else to stdout.
+func NewSplitConsoleHandler(opts ...ConsoleHandlerOption) Handler {
+	return NewConsoleHandler(append([]ConsoleHandlerOption{WithConsoleSplitLevel(WarnLevel)}, opts...)...)
 }
 
 // Handle implements the Handler interface for console output
@@ -28,12 +83,22 @@ func (h *ConsoleHandler) Handle(entry *Entry) error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	formatted, err := h.formatter.Format(entry)
+	out, colors := h.stdout, h.outColors
+	if h.split && entry.Level.Value >= h.splitLevel.Value {
+		out, colors = h.stderr, h.errColors
+	}
+
+	formatter := handlerFormatter(h.formatter, entry)
+	if !colors {
+		formatter = h.plain.get(formatter)
+	}
+
+	formatted, err := formatter.Format(entry)
 	if err != nil {
 		return err
 	}
 
-	_, err = fmt.Fprintln(os.Stdout, string(formatted))
+	_, err = out.Write(append(formatted, '\n'))
 	return err
 }
 
@@ -44,24 +109,207 @@ func (h *ConsoleHandler) SetFormatter(formatter Formatter) {
 	h.formatter = formatter
 }
 
+// WriterHandler handles logging to any io.Writer
+//
+// Output can optionally be buffered; call Flush or Sync to push buffered
+// entries to the underlying writer.
+type WriterHandler struct {
+	out       io.Writer
+	buf       *bufio.Writer
+	formatter Formatter
+	plain     plainFormatterCache
+	colors    bool
+	mu        sync.Mutex
+}
+
+// WriterHandlerOption is a functional option for WriterHandler configuration.
+type WriterHandlerOption func(*WriterHandler)
+
+// WithWriterFormatter sets the formatter for the writer handler.
+func WithWriterFormatter(formatter Formatter) WriterHandlerOption {
+	return func(h *WriterHandler) {
+		h.formatter = formatter
+	}
+}
+
+// WithWriterBuffer enables buffered output with the given buffer size in bytes.
+func WithWriterBuffer(size int) WriterHandlerOption {
+	return func(h *WriterHandler) {
+		if size > 0 {
+			h.buf = bufio.NewWriterSize(h.out, size)
+		}
+	}
+}
+
+// NewWriterHandler creates a new handler writing to w
+func NewWriterHandler(w io.Writer, opts ...WriterHandlerOption) *WriterHandler {
+	h := &WriterHandler{
+		out:    w,
+		colors: colorsEnabled(w),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Handle implements the Handler interface for writer output
+func (h *WriterHandler) Handle(entry *Entry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	formatter := handlerFormatter(h.formatter, entry)
+	if !h.colors {
+		formatter = h.plain.get(formatter)
+	}
+
+	formatted, err := formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+
+	formatted = append(formatted, '\n')
+	if h.buf != nil {
+		_, err = h.buf.Write(formatted)
+	} else {
+		_, err = h.out.Write(formatted)
+	}
+	return err
+}
+
+// SetFormatter sets the formatter for the writer handler
+func (h *WriterHandler) SetFormatter(formatter Formatter) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.formatter = formatter
+}
+
+// Flush writes any buffered entries to the underlying writer
+func (h *WriterHandler) Flush() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.buf == nil {
+		return nil
+	}
+	return h.buf.Flush()
+}
+
+// Sync flushes buffered entries and commits them to stable storage if the
+// underlying writer supports it (e.g. *os.File)
+func (h *WriterHandler) Sync() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.buf != nil {
+		if err := h.buf.Flush(); err != nil {
+			return err
+		}
+	}
+	if s, ok := h.out.(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+// IsTerminal reports whether w is connected to a terminal
+func IsTerminal(w io.Writer) bool {
+	f, ok := w.(interface{ Fd() uintptr })
+	if !ok {
+		return false
+	}
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+// colorsEnabled reports whether colored output should be written to w
+func colorsEnabled(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return IsTerminal(w)
+}
+
+// defaultHandlerFormatter is used by handlers when neither the handler nor
+// the logger has a formatter configured
+var defaultHandlerFormatter = NewTextFormatter()
+
+// handlerFormatter returns the formatter a handler should use for entry: its
+// own formatter if set, then the logger's formatter, then the default
+func handlerFormatter(own Formatter, entry *Entry) Formatter {
+	if own != nil {
+		return own
+	}
+	if entry.Formatter != nil {
+		return entry.Formatter
+	}
+	return defaultHandlerFormatter
+}
+
+// plainFormatterCache memoizes the colorless variant of a TextFormatter or
+// DevFormatter so it is not rebuilt for every entry
+type plainFormatterCache struct {
+	src   Formatter
+	plain Formatter
+}
+
+// get returns a variant of formatter that does not emit colors
+func (c *plainFormatterCache) get(formatter Formatter) Formatter {
+	if c.src == formatter && c.plain != nil {
+		return c.plain
+	}
+
+	switch f := formatter.(type) {
+	case *TextFormatter:
+		if !f.UseColors {
+			return formatter
+		}
+		plain := *f
+		plain.UseColors = false
+		c.plain = &plain
+	case *DevFormatter:
+		if !f.UseColors {
+			return formatter
+		}
+		plain := *f
+		plain.UseColors = false
+		c.plain = &plain
+	default:
+		return formatter
+	}
+	c.src = formatter
+	return c.plain
+}
+
 // FileHandler handles logging to a file
 type FileHandler struct {
+	filename  string
 	file      *os.File
 	formatter Formatter
+	plain     plainFormatterCache
+	guard     *fileGuard
+	buf       *fileBuffer
 	mu        sync.Mutex
 }
 
 // NewFileHandler creates a new file handler
-func NewFileHandler(filename string) (Handler, error) {
-	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+func NewFileHandler(filename string, opts ...FileOption) (Handler, error) {
+	file, err := openLogFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	handler := &FileHandler{
+		filename: filename,
+		file:     file,
+	}
+
+	options := applyFileOptions(opts)
+	handler.guard, err = newFileGuard(filename, options, handler.Reopen)
 	if err != nil {
+		file.Close()
 		return nil, err
 	}
+	handler.buf = newFileBuffer(options, handler.Flush)
 
-	return &FileHandler{
-		file:      file,
-		formatter: NewTextFormatter(),
-	}, nil
+	return handler, nil
 }
 
 // Handle implements the Handler interface for file output
@@ -69,13 +317,30 @@ func (h *FileHandler) Handle(entry *Entry) error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	formatted, err := h.formatter.Format(entry)
+	formatted, err := h.plain.get(handlerFormatter(h.formatter, entry)).Format(entry)
 	if err != nil {
 		return err
 	}
 
-	_, err = h.file.Write(append(formatted, '\n'))
-	return err
+	if err := h.guard.lock(); err != nil {
+		return err
+	}
+	defer h.guard.unlock()
+
+	if h.guard.changed(h.filename, h.file) {
+		if err := h.reopen(); err != nil {
+			return err
+		}
+	}
+
+	return h.buf.write(h.file, append(formatted, '\n'), entry.Level)
+}
+
+// Flush writes buffered entries to the file, syncing it with FileSyncInterval
+func (h *FileHandler) Flush() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.buf.flushAndSync(h.file)
 }
 
 // SetFormatter sets the formatter for the file handler
@@ -85,11 +350,38 @@ func (h *FileHandler) SetFormatter(formatter Formatter) {
 	h.formatter = formatter
 }
 
-// Close closes the file handler
+// Reopen closes and reopens the log file, e.g. after it was moved by logrotate
+func (h *FileHandler) Reopen() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.reopen()
+}
+
+// reopen replaces the open file with a new handle for filename
+func (h *FileHandler) reopen() error {
+	file, err := openLogFile(h.filename)
+	if err != nil {
+		return err
+	}
+	h.buf.flush()
+	h.file.Close()
+	h.file = file
+	return nil
+}
+
+// Close flushes and closes the file handler
 func (h *FileHandler) Close() error {
+	h.guard.stopSignals()
+	h.buf.close()
+
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	return h.file.Close()
+	h.guard.closeLock()
+	flushErr := h.buf.flushAndSync(h.file)
+	if err := h.file.Close(); err != nil {
+		return err
+	}
+	return flushErr
 }
 
 // RotatingFileHandler handles logging to rotating files
@@ -99,29 +391,40 @@ type RotatingFileHandler struct {
 	maxFiles    int
 	currentFile *os.File
 	formatter   Formatter
+	plain       plainFormatterCache
+	guard       *fileGuard
+	buf         *fileBuffer
 	mu          sync.Mutex
 	currentSize int64
 }
 
 // NewRotatingFileHandler creates a new rotating file handler
-func NewRotatingFileHandler(filename string, maxSize int64, maxFiles int) (Handler, error) {
+func NewRotatingFileHandler(filename string, maxSize int64, maxFiles int, opts ...FileOption) (Handler, error) {
 	handler := &RotatingFileHandler{
-		filename:  filename,
-		maxSize:   maxSize,
-		maxFiles:  maxFiles,
-		formatter: NewTextFormatter(),
+		filename: filename,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
 	}
 
 	if err := handler.openFile(); err != nil {
 		return nil, err
 	}
 
+	options := applyFileOptions(opts)
+	var err error
+	handler.guard, err = newFileGuard(filename, options, handler.Reopen)
+	if err != nil {
+		handler.currentFile.Close()
+		return nil, err
+	}
+	handler.buf = newFileBuffer(options, handler.Flush)
+
 	return handler, nil
 }
 
 // openFile opens the current log file
 func (h *RotatingFileHandler) openFile() error {
-	file, err := os.OpenFile(h.filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	file, err := openLogFile(h.filename)
 	if err != nil {
 		return err
 	}
@@ -141,6 +444,7 @@ func (h *RotatingFileHandler) openFile() error {
 // rotate rotates the log file
 func (h *RotatingFileHandler) rotate() error {
 	if h.currentFile != nil {
+		h.buf.flush()
 		h.currentFile.Close()
 	}
 
@@ -167,11 +471,20 @@ func (h *RotatingFileHandler) Handle(entry *Entry) error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	formatted, err := h.formatter.Format(entry)
+	formatted, err := h.plain.get(handlerFormatter(h.formatter, entry)).Format(entry)
 	if err != nil {
 		return err
 	}
 
+	if err := h.guard.lock(); err != nil {
+		return err
+	}
+	defer h.guard.unlock()
+
+	if err := h.syncWithDisk(); err != nil {
+		return err
+	}
+
 	// Check if we need to rotate
 	if h.currentSize+int64(len(formatted)+1) > h.maxSize {
 		if err := h.rotate(); err != nil {
@@ -179,13 +492,39 @@ func (h *RotatingFileHandler) Handle(entry *Entry) error {
 		}
 	}
 
-	_, err = h.currentFile.Write(append(formatted, '\n'))
+	err = h.buf.write(h.currentFile, append(formatted, '\n'), entry.Level)
 	if err == nil {
 		h.currentSize += int64(len(formatted) + 1)
 	}
 	return err
 }
 
+// Flush writes buffered entries to the file, syncing it with FileSyncInterval
+func (h *RotatingFileHandler) Flush() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.buf.flushAndSync(h.currentFile)
+}
+
+// syncWithDisk reopens the file if another process rotated it and refreshes
+// the current size, which other writers or a truncation may have changed
+func (h *RotatingFileHandler) syncWithDisk() error {
+	if h.guard.changed(h.filename, h.currentFile) {
+		h.buf.flush()
+		h.currentFile.Close()
+		return h.openFile()
+	}
+
+	if h.guard.opts.locking || h.guard.opts.detectRotate {
+		info, err := h.currentFile.Stat()
+		if err != nil {
+			return err
+		}
+		h.currentSize = info.Size() + int64(h.buf.buffered())
+	}
+	return nil
+}
+
 // SetFormatter sets the formatter for the rotating file handler
 func (h *RotatingFileHandler) SetFormatter(formatter Formatter) {
 	h.mu.Lock()
@@ -193,17 +532,46 @@ func (h *RotatingFileHandler) SetFormatter(formatter Formatter) {
 	h.formatter = formatter
 }
 
-// Close closes the rotating file handler
+// Reopen closes and reopens the log file, e.g. after it was moved or
+// truncated by logrotate
+func (h *RotatingFileHandler) Reopen() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.currentFile != nil {
+		h.buf.flush()
+		h.currentFile.Close()
+	}
+	return h.openFile()
+}
+
+// Close flushes and closes the rotating file handler
 func (h *RotatingFileHandler) Close() error {
+	h.guard.stopSignals()
+	h.buf.close()
+
 	h.mu.Lock()
 	defer h.mu.Unlock()
+	h.guard.closeLock()
 	if h.currentFile != nil {
-		return h.currentFile.Close()
+		flushErr := h.buf.flushAndSync(h.currentFile)
+		if err := h.currentFile.Close(); err != nil {
+			return err
+		}
+		return flushErr
 	}
 	return nil
 }
 
+// openLogFile opens filename for appending, creating it if needed
+func openLogFile(filename string) (*os.File, error) {
+	return os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+}
+
 // HTTPHandler handles logging via HTTP requests
+//
+// Entries are always posted as JSON unless a formatter is set on the handler
+// itself; the logger's formatter is not used.
 type HTTPHandler struct {
 	endpoint  string
 	client    *http.Client
@@ -245,7 +613,7 @@ func (h *HTTPHandler) Handle(entry *Entry) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		return fmt.Errorf("HTTP request failed with status: %d", resp.StatusCode)
+		return &HTTPStatusError{StatusCode: resp.StatusCode}
 	}
 
 	return nil
@@ -304,10 +672,11 @@ func (h *AsyncHandler) worker() {
 	}
 }
 
-// Handle implements the Handler interface for async output
+// Handle implements the Handler interface for async output. Queued entries
+// are cloned, since the logger reuses the entry once Handle returns.
 func (h *AsyncHandler) Handle(entry *Entry) error {
 	select {
-	case h.buffer <- entry:
+	case h.buffer <- entry.Clone():
 		return nil
 	default:
 		// Buffer is full, log synchronously
@@ -355,32 +724,171 @@ func (h *SamplingHandler) Handle(entry *Entry) error {
 	return h.handler.Handle(entry)
 }
 
+// ErrHandlerTimeout is returned for a MultiHandler child that did not finish
+// within its timeout
+var ErrHandlerTimeout = errors.New("handler timed out")
+
+// ChildHandler wraps a MultiHandler child with a name used in errors, a
+// minimum level and a timeout
+type ChildHandler struct {
+	Name     string
+	Handler  Handler
+	MinLevel Level
+
+	// Timeout bounds how long MultiHandler waits for the child (0 = no limit,
+	// or the MultiHandler's default timeout)
+	Timeout time.Duration
+}
+
+// ChildOption configures a ChildHandler
+type ChildOption func(*ChildHandler)
+
+// WithChildName sets the name reported in errors from the child
+func WithChildName(name string) ChildOption {
+	return func(c *ChildHandler) {
+		c.Name = name
+	}
+}
+
+// WithChildMinLevel sets the lowest level passed to the child
+func WithChildMinLevel(level Level) ChildOption {
+	return func(c *ChildHandler) {
+		c.MinLevel = level
+	}
+}
+
+// WithChildTimeout sets how long MultiHandler waits for the child
+func WithChildTimeout(timeout time.Duration) ChildOption {
+	return func(c *ChildHandler) {
+		c.Timeout = timeout
+	}
+}
+
+// NewChildHandler wraps handler for use in a MultiHandler
+func NewChildHandler(handler Handler, opts ...ChildOption) *ChildHandler {
+	c := &ChildHandler{Handler: handler}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Handle implements the Handler interface, skipping entries below MinLevel
+func (c *ChildHandler) Handle(entry *Entry) error {
+	if entry.Level.Value < c.MinLevel.Value {
+		return nil
+	}
+	return c.Handler.Handle(entry)
+}
+
 // MultiHandler handles logging to multiple handlers
+//
+// Children wrapped with NewChildHandler get a name, minimum level and
+// timeout. A child with a timeout receives its own copy of the entry, since
+// it may still be running after Handle returns. Errors from all children are
+// joined with errors.Join, each prefixed with the child's name.
 type MultiHandler struct {
 	handlers []Handler
+	parallel bool
+	timeout  time.Duration
 	mu       sync.RWMutex
 }
 
-// NewMultiHandler creates a new multi handler
+// NewMultiHandler creates a new multi handler that calls handlers in order
 func NewMultiHandler(handlers ...Handler) Handler {
 	return &MultiHandler{
 		handlers: handlers,
 	}
 }
 
+// NewParallelMultiHandler creates a multi handler that calls all handlers
+// concurrently, waiting at most timeout for each (0 = no limit). Children
+// without a timeout share the entry and must not modify it.
+func NewParallelMultiHandler(timeout time.Duration, handlers ...Handler) *MultiHandler {
+	return &MultiHandler{
+		handlers: handlers,
+		parallel: true,
+		timeout:  timeout,
+	}
+}
+
 // Handle implements the Handler interface for multiple handlers
 func (h *MultiHandler) Handle(entry *Entry) error {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
-	var lastErr error
-	for _, handler := range h.handlers {
-		if err := handler.Handle(entry); err != nil {
-			lastErr = err
+	errs := make([]error, len(h.handlers))
+	if h.parallel {
+		var wg sync.WaitGroup
+		for i, handler := range h.handlers {
+			wg.Add(1)
+			go func(i int, handler Handler) {
+				defer wg.Done()
+				errs[i] = h.handleChild(i, handler, entry)
+			}(i, handler)
+		}
+		wg.Wait()
+	} else {
+		for i, handler := range h.handlers {
+			errs[i] = h.handleChild(i, handler, entry)
 		}
 	}
 
-	return lastErr
+	return errors.Join(errs...)
+}
+
+// handleChild passes entry to one child, applying its level and timeout
+func (h *MultiHandler) handleChild(i int, handler Handler, entry *Entry) error {
+	name := fmt.Sprintf("#%d (%T)", i, handler)
+	timeout := h.timeout
+	if child, ok := handler.(*ChildHandler); ok {
+		if entry.Level.Value < child.MinLevel.Value {
+			return nil
+		}
+		if child.Name != "" {
+			name = child.Name
+		}
+		if child.Timeout > 0 {
+			timeout = child.Timeout
+		}
+		handler = child.Handler
+	}
+
+	// Children that run concurrently get their own copy, since wrappers
+	// such as ContainerHandler replace the entry's fields
+	if h.parallel || timeout > 0 {
+		entry = entry.Clone()
+	}
+
+	var err error
+	if timeout > 0 {
+		err = handleWithTimeout(handler, entry, timeout)
+	} else {
+		err = handler.Handle(entry)
+	}
+	if err != nil {
+		return fmt.Errorf("handler %s: %w", name, err)
+	}
+	return nil
+}
+
+// handleWithTimeout calls handler in a goroutine and returns ErrHandlerTimeout
+// if it does not finish in time; the goroutine is left to complete on its own
+func handleWithTimeout(handler Handler, entry *Entry, timeout time.Duration) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- handler.Handle(entry)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return fmt.Errorf("%w after %v", ErrHandlerTimeout, timeout)
+	}
 }
 
 // AddHandler adds a new handler to the multi handler
@@ -434,10 +942,13 @@ func (h *JSONHandler) Handle(entry *Entry) error {
 		return err
 	}
 
-	// Write to the underlying handler
+	// Write to the underlying handler, which still renders it with the
+	// logger's formatter
 	return h.handler.Handle(&Entry{
-		Level:   entry.Level,
-		Message: string(formatted),
-		Time:    entry.Time,
+		Level:     entry.Level,
+		Message:   string(formatted),
+		Time:      entry.Time,
+		Context:   entry.Context,
+		Formatter: entry.Formatter,
 	})
 }
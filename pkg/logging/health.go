@@ -306,7 +306,7 @@ func (cb *CircuitBreaker) Execute(fn func() error) error {
 		if time.Since(cb.lastFailure) > cb.timeout {
 			cb.setState(CircuitStateHalfOpen)
 		} else {
-			return fmt.Errorf("circuit breaker is open")
+			return ErrCircuitOpen
 		}
 	}
 
@@ -0,0 +1,289 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// appendJSONValue appends the JSON encoding of v to buf. Common types are
+// encoded directly; anything else falls back to encoding/json.
+func appendJSONValue(buf []byte, v interface{}, escapeHTML bool) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(buf, "null"...), nil
+	case string:
+		return appendJSONString(buf, v, escapeHTML), nil
+	case bool:
+		return strconv.AppendBool(buf, v), nil
+	case int:
+		return strconv.AppendInt(buf, int64(v), 10), nil
+	case int8:
+		return strconv.AppendInt(buf, int64(v), 10), nil
+	case int16:
+		return strconv.AppendInt(buf, int64(v), 10), nil
+	case int32:
+		return strconv.AppendInt(buf, int64(v), 10), nil
+	case int64:
+		return strconv.AppendInt(buf, v, 10), nil
+	case uint:
+		return strconv.AppendUint(buf, uint64(v), 10), nil
+	case uint8:
+		return strconv.AppendUint(buf, uint64(v), 10), nil
+	case uint16:
+		return strconv.AppendUint(buf, uint64(v), 10), nil
+	case uint32:
+		return strconv.AppendUint(buf, uint64(v), 10), nil
+	case uint64:
+		return strconv.AppendUint(buf, v, 10), nil
+	case float32:
+		return appendJSONFloat(buf, float64(v), 32, escapeHTML), nil
+	case float64:
+		return appendJSONFloat(buf, v, 64, escapeHTML), nil
+	case time.Time:
+		buf = append(buf, '"')
+		buf = v.AppendFormat(buf, time.RFC3339Nano)
+		return append(buf, '"'), nil
+	case time.Duration:
+		return strconv.AppendInt(buf, int64(v), 10), nil
+	case StackTrace:
+		return appendJSONStack(buf, v, escapeHTML), nil
+	case json.Marshaler:
+		return appendJSONFallback(buf, v, escapeHTML)
+	case error:
+		if isNilPointer(v) {
+			return append(buf, "null"...), nil
+		}
+		return appendJSONString(buf, v.Error(), escapeHTML), nil
+	case Fields:
+		return appendJSONObject(buf, v, escapeHTML)
+	case map[string]interface{}:
+		return appendJSONObject(buf, v, escapeHTML)
+	case map[string]string:
+		buf = append(buf, '{')
+		for i, k := range sortedStringKeys(v) {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = appendJSONString(buf, k, escapeHTML)
+			buf = append(buf, ':')
+			buf = appendJSONString(buf, v[k], escapeHTML)
+		}
+		return append(buf, '}'), nil
+	case []string:
+		if v == nil {
+			return append(buf, "null"...), nil
+		}
+		buf = append(buf, '[')
+		for i, s := range v {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			buf = appendJSONString(buf, s, escapeHTML)
+		}
+		return append(buf, ']'), nil
+	case []interface{}:
+		if v == nil {
+			return append(buf, "null"...), nil
+		}
+		buf = append(buf, '[')
+		for i, item := range v {
+			if i > 0 {
+				buf = append(buf, ',')
+			}
+			var err error
+			if buf, err = appendJSONValue(buf, item, escapeHTML); err != nil {
+				return buf, err
+			}
+		}
+		return append(buf, ']'), nil
+	default:
+		return appendJSONFallback(buf, v, escapeHTML)
+	}
+}
+
+// appendJSONObject appends a map as a JSON object with sorted keys
+func appendJSONObject(buf []byte, m map[string]interface{}, escapeHTML bool) ([]byte, error) {
+	if m == nil {
+		return append(buf, "null"...), nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	buf = append(buf, '{')
+	for i, k := range keys {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = appendJSONString(buf, k, escapeHTML)
+		buf = append(buf, ':')
+		var err error
+		if buf, err = appendJSONValue(buf, m[k], escapeHTML); err != nil {
+			return buf, err
+		}
+	}
+	return append(buf, '}'), nil
+}
+
+// appendJSONStack appends a stack trace as an array of frame objects
+func appendJSONStack(buf []byte, st StackTrace, escapeHTML bool) []byte {
+	if st == nil {
+		return append(buf, "null"...)
+	}
+	buf = append(buf, '[')
+	for i, fr := range st {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, `{"function":`...)
+		buf = appendJSONString(buf, fr.Function, escapeHTML)
+		buf = append(buf, `,"file":`...)
+		buf = appendJSONString(buf, fr.File, escapeHTML)
+		buf = append(buf, `,"line":`...)
+		buf = strconv.AppendInt(buf, int64(fr.Line), 10)
+		buf = append(buf, '}')
+	}
+	return append(buf, ']')
+}
+
+// appendJSONFallback encodes v with encoding/json
+func appendJSONFallback(buf []byte, v interface{}, escapeHTML bool) ([]byte, error) {
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(escapeHTML)
+	if err := enc.Encode(v); err != nil {
+		return buf, err
+	}
+	return append(buf, bytes.TrimRight(out.Bytes(), "\n")...), nil
+}
+
+// appendJSONFloat appends f like encoding/json does. NaN and infinities,
+// which JSON cannot represent, are written as strings.
+func appendJSONFloat(buf []byte, f float64, bits int, escapeHTML bool) []byte {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return appendJSONString(buf, strconv.FormatFloat(f, 'g', -1, bits), escapeHTML)
+	}
+
+	abs := math.Abs(f)
+	format := byte('f')
+	if abs != 0 && (bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21)) {
+		format = 'e'
+	}
+	buf = strconv.AppendFloat(buf, f, format, -1, bits)
+	if format == 'e' {
+		// Clean up e-09 to e-9
+		n := len(buf)
+		if n >= 4 && buf[n-4] == 'e' && buf[n-3] == '-' && buf[n-2] == '0' {
+			buf[n-2] = buf[n-1]
+			buf = buf[:n-1]
+		}
+	}
+	return buf
+}
+
+const jsonHex = "0123456789abcdef"
+
+// appendJSONString appends s as a quoted JSON string, escaping it the way
+// encoding/json does
+func appendJSONString(buf []byte, s string, escapeHTML bool) []byte {
+	buf = append(buf, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= ' ' && b != '"' && b != '\\' && (!escapeHTML || b != '<' && b != '>' && b != '&') {
+				i++
+				continue
+			}
+			buf = append(buf, s[start:i]...)
+			switch b {
+			case '"', '\\':
+				buf = append(buf, '\\', b)
+			case '\n':
+				buf = append(buf, '\\', 'n')
+			case '\r':
+				buf = append(buf, '\\', 'r')
+			case '\t':
+				buf = append(buf, '\\', 't')
+			default:
+				buf = append(buf, '\\', 'u', '0', '0', jsonHex[b>>4], jsonHex[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, '\\', 'u', '2', '0', '2', jsonHex[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	buf = append(buf, s[start:]...)
+	return append(buf, '"')
+}
+
+// isNilPointer reports whether v holds a nil pointer, on which methods such
+// as Error may panic
+func isNilPointer(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Ptr && rv.IsNil()
+}
+
+// errorValue returns the message of err for JSON documents, or nil for a
+// nil pointer error so it is written as null
+func errorValue(err error) interface{} {
+	if isNilPointer(err) {
+		return nil
+	}
+	return err.Error()
+}
+
+// fieldString renders a field value as text, using Error or String where
+// implemented. Nil pointers render as "<nil>" instead of calling methods
+// that may dereference them.
+func fieldString(value interface{}) string {
+	if value == nil || isNilPointer(value) {
+		return "<nil>"
+	}
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprint(value)
+}
+
+// sortedStringKeys returns the keys of m in sorted order
+func sortedStringKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,379 @@
+package logging
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+	"unicode/utf8"
+)
+
+// TruncatedField lists what Limits cut from an entry: "message", the keys
+// of shortened or dropped fields, and "fields" when fields were dropped
+// because of MaxFields
+const TruncatedField = "_truncated"
+
+// DefaultTruncationMarker is appended to shortened strings and replaces
+// values nested deeper than MaxDepth
+const DefaultTruncationMarker = "...[truncated]"
+
+// Limits bounds the size of log entries so a single oversized message or
+// field cannot produce lines that downstream collectors reject. Zero values
+// disable the corresponding limit. Lengths are in bytes; strings are cut at
+// a UTF-8 boundary.
+type Limits struct {
+	// MaxMessageLength limits the message
+	MaxMessageLength int `yaml:"max_message_length" json:"max_message_length"`
+
+	// MaxFieldLength limits string, byte slice, error and fmt.Stringer
+	// values, including those nested in maps and slices. Other slices, maps
+	// and structs longer than this in JSON are replaced by their cut JSON
+	// text.
+	MaxFieldLength int `yaml:"max_field_length" json:"max_field_length"`
+
+	// MaxFields limits the number of fields, counting TruncatedField;
+	// fields are kept in key order
+	MaxFields int `yaml:"max_fields" json:"max_fields"`
+
+	// MaxDepth limits how deeply maps and slices may nest inside a field
+	// value; deeper values are replaced by the marker
+	MaxDepth int `yaml:"max_depth" json:"max_depth"`
+
+	// MaxEntrySize limits the formatted entry. Larger entries have their
+	// biggest fields shortened or dropped, then their message shortened,
+	// until they fit.
+	MaxEntrySize int `yaml:"max_entry_size" json:"max_entry_size"`
+
+	// Formatter measures entries for MaxEntrySize. It defaults to the
+	// logger's formatter; set it when the handler formats entries itself.
+	Formatter Formatter `yaml:"-" json:"-"`
+
+	// Marker is appended to shortened strings (default
+	// DefaultTruncationMarker)
+	Marker string `yaml:"marker" json:"marker"`
+}
+
+// enabled reports whether any limit is set
+func (lim Limits) enabled() bool {
+	return lim.MaxMessageLength > 0 || lim.MaxFieldLength > 0 || lim.MaxFields > 0 ||
+		lim.MaxDepth > 0 || lim.MaxEntrySize > 0
+}
+
+// WithLimits sets size limits applied to every entry after hooks run and
+// before it reaches the handler. Truncated entries carry a TruncatedField
+// listing what was cut.
+func WithLimits(limits Limits) Option {
+	return func(l *logger) {
+		l.limits = limits
+	}
+}
+
+// LimitHandler applies Limits to entries before passing them to another
+// handler, for handlers fed by loggers without limits of their own.
+type LimitHandler struct {
+	handler Handler
+	limits  Limits
+}
+
+// NewLimitHandler creates a handler that enforces limits on entries
+// passed to handler.
+func NewLimitHandler(handler Handler, limits Limits) Handler {
+	return &LimitHandler{handler: handler, limits: limits}
+}
+
+// Handle implements the Handler interface, passing a limited copy of the
+// entry on
+func (h *LimitHandler) Handle(entry *Entry) error {
+	limited := *entry
+	h.limits.apply(&limited)
+	return h.handler.Handle(&limited)
+}
+
+// apply enforces the limits on entry. The entry's Fields map is replaced
+// rather than modified, since it is shared with the logger.
+func (lim Limits) apply(entry *Entry) {
+	if !lim.enabled() {
+		return
+	}
+	t := truncator{Limits: lim, entry: entry}
+	if t.Marker == "" {
+		t.Marker = DefaultTruncationMarker
+	}
+
+	if t.MaxMessageLength > 0 && len(entry.Message) > t.MaxMessageLength {
+		entry.Message = t.cut(entry.Message, t.MaxMessageLength)
+		t.mark("message")
+	}
+
+	t.limitFields()
+
+	if t.MaxFieldLength > 0 || t.MaxDepth > 0 {
+		for k, v := range entry.Fields {
+			if k == TruncatedField || k == MetricsField {
+				continue
+			}
+			if limited, changed := t.value(v, 0); changed {
+				t.set(k, limited)
+				t.mark(k)
+			}
+		}
+	}
+
+	if t.MaxEntrySize > 0 {
+		t.fitEntry()
+	}
+
+	// Marks added since may have pushed the count over again
+	t.limitFields()
+}
+
+// limitFields drops fields beyond MaxFields in key order, keeping room for
+// TruncatedField
+func (t *truncator) limitFields() {
+	if t.MaxFields <= 0 || len(t.entry.Fields) <= t.MaxFields {
+		return
+	}
+	t.mark("fields")
+
+	keys := make([]string, 0, len(t.entry.Fields))
+	for k := range t.entry.Fields {
+		if k != TruncatedField {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	keep := t.MaxFields - 1
+	if keep < 0 {
+		keep = 0
+	}
+	for _, k := range keys[keep:] {
+		t.delete(k)
+	}
+}
+
+// truncator applies Limits to one entry, copying its fields on first write
+type truncator struct {
+	Limits
+	entry     *Entry
+	copied    bool
+	truncated []string
+}
+
+func (t *truncator) copyFields() {
+	if t.copied {
+		return
+	}
+	fields := make(Fields, len(t.entry.Fields)+1)
+	for k, v := range t.entry.Fields {
+		fields[k] = v
+	}
+	t.entry.Fields = fields
+	t.copied = true
+}
+
+func (t *truncator) set(key string, v interface{}) {
+	t.copyFields()
+	t.entry.Fields[key] = v
+}
+
+func (t *truncator) delete(key string) {
+	t.copyFields()
+	delete(t.entry.Fields, key)
+}
+
+// mark records what was truncated in the entry's TruncatedField
+func (t *truncator) mark(what string) {
+	for _, s := range t.truncated {
+		if s == what {
+			return
+		}
+	}
+	t.truncated = append(t.truncated, what)
+	sort.Strings(t.truncated)
+	t.set(TruncatedField, t.truncated)
+}
+
+// cut shortens s to at most n bytes including the marker, which is itself
+// cut when n is smaller than the marker
+func (t *truncator) cut(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	if n < 0 {
+		n = 0
+	}
+	if n < len(t.Marker) {
+		return truncateUTF8(t.Marker, n)
+	}
+	return truncateUTF8(s, n-len(t.Marker)) + t.Marker
+}
+
+// truncateUTF8 returns the longest prefix of s of at most n bytes that
+// does not split a rune
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// value limits the length of strings in v and the nesting of maps and
+// slices, reporting whether anything changed
+func (t *truncator) value(v interface{}, depth int) (interface{}, bool) {
+	switch v := v.(type) {
+	case string:
+		if t.MaxFieldLength > 0 && len(v) > t.MaxFieldLength {
+			return t.cut(v, t.MaxFieldLength), true
+		}
+	case []byte:
+		if t.MaxFieldLength > 0 && len(v) > t.MaxFieldLength {
+			return v[:t.MaxFieldLength], true
+		}
+	case error:
+		if isNilPointer(v) {
+			return v, false
+		}
+		if s := v.Error(); t.MaxFieldLength > 0 && len(s) > t.MaxFieldLength {
+			return t.cut(s, t.MaxFieldLength), true
+		}
+	case time.Time, time.Duration, StackTrace:
+		// Kept as they are for formatters to render
+	case fmt.Stringer:
+		if isNilPointer(v) {
+			return v, false
+		}
+		if s := v.String(); t.MaxFieldLength > 0 && len(s) > t.MaxFieldLength {
+			return t.cut(s, t.MaxFieldLength), true
+		}
+	case Fields:
+		return t.mapValue(v, depth)
+	case map[string]interface{}:
+		return t.mapValue(v, depth)
+	case []interface{}:
+		if t.MaxDepth > 0 && depth >= t.MaxDepth {
+			return t.Marker, true
+		}
+		var items []interface{}
+		for i, item := range v {
+			if limited, changed := t.value(item, depth+1); changed {
+				if items == nil {
+					items = append([]interface{}(nil), v...)
+				}
+				items[i] = limited
+			}
+		}
+		if items != nil {
+			return items, true
+		}
+	default:
+		return t.otherValue(v)
+	}
+	return v, false
+}
+
+// otherValue limits slices, maps and structs of other types, such as
+// []string and map[string]string, by the length of their JSON form
+func (t *truncator) otherValue(v interface{}) (interface{}, bool) {
+	if t.MaxFieldLength <= 0 || v == nil {
+		return v, false
+	}
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct, reflect.Ptr:
+	default:
+		return v, false
+	}
+	data, err := appendJSONValue(nil, v, false)
+	if err != nil {
+		data = []byte(fmt.Sprint(v))
+	}
+	if len(data) <= t.MaxFieldLength {
+		return v, false
+	}
+	return t.cut(string(data), t.MaxFieldLength), true
+}
+
+func (t *truncator) mapValue(m map[string]interface{}, depth int) (interface{}, bool) {
+	if t.MaxDepth > 0 && depth >= t.MaxDepth {
+		return t.Marker, true
+	}
+	var out map[string]interface{}
+	for k, v := range m {
+		if limited, changed := t.value(v, depth+1); changed {
+			if out == nil {
+				out = make(map[string]interface{}, len(m))
+				for k2, v2 := range m {
+					out[k2] = v2
+				}
+			}
+			out[k] = limited
+		}
+	}
+	if out != nil {
+		return out, true
+	}
+	return m, false
+}
+
+// fitEntry shortens or drops the largest fields, then the message, until
+// the formatted entry fits in MaxEntrySize. It is best effort: an entry
+// whose fixed parts alone exceed the limit is passed on as it is.
+func (t *truncator) fitEntry() {
+	excess := t.size() - t.MaxEntrySize
+	if excess <= 0 {
+		return
+	}
+
+	type candidate struct {
+		key  string
+		size int
+	}
+	candidates := make([]candidate, 0, len(t.entry.Fields))
+	for k, v := range t.entry.Fields {
+		if k == TruncatedField {
+			continue
+		}
+		data, err := appendJSONValue(nil, v, false)
+		if err != nil {
+			data = nil
+		}
+		candidates = append(candidates, candidate{k, len(data)})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].size != candidates[j].size {
+			return candidates[i].size > candidates[j].size
+		}
+		return candidates[i].key < candidates[j].key
+	})
+
+	// Marks are added before measuring, since they count towards the size
+	for _, c := range candidates {
+		t.mark(c.key)
+		excess = t.size() - t.MaxEntrySize
+		if s, ok := t.entry.Fields[c.key].(string); ok && len(s) > excess+len(t.Marker) {
+			t.set(c.key, t.cut(s, len(s)-excess))
+		} else {
+			t.delete(c.key)
+		}
+		if excess = t.size() - t.MaxEntrySize; excess <= 0 {
+			return
+		}
+	}
+
+	if t.entry.Message != "" {
+		t.mark("message")
+		excess = t.size() - t.MaxEntrySize
+		t.entry.Message = t.cut(t.entry.Message, len(t.entry.Message)-excess)
+	}
+}
+
+// size returns the length of the formatted entry
+func (t *truncator) size() int {
+	data, err := handlerFormatter(t.Formatter, t.entry).Format(t.entry)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
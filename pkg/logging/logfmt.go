@@ -0,0 +1,197 @@
+package logging
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// LogfmtFormatterOption is a functional option for LogfmtFormatter configuration.
+type LogfmtFormatterOption func(*LogfmtFormatter)
+
+// LogfmtFormatter formats log entries as logfmt:
+//
+//	ts=2024-01-02T15:04:05Z level=info msg="user logged in" user.id=42
+//
+// Keys are written as ts, level, msg and caller, followed by the fields in
+// FieldOrder and then the remaining fields sorted by key. Nested maps are
+// flattened into dotted keys. Fields named like a built-in key are written
+// with a "fields." prefix.
+type LogfmtFormatter struct {
+	// TimestampFormat is the layout for ts and time.Time values (default RFC3339Nano)
+	TimestampFormat string
+
+	// DisableTimestamp omits the ts key
+	DisableTimestamp bool
+
+	// FieldOrder lists fields written before the sorted remainder
+	FieldOrder []string
+}
+
+// NewLogfmtFormatter creates a new logfmt formatter with options.
+func NewLogfmtFormatter(opts ...LogfmtFormatterOption) Formatter {
+	f := &LogfmtFormatter{
+		TimestampFormat: time.RFC3339Nano,
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// WithLogfmtTimestampFormat sets the timestamp layout.
+func WithLogfmtTimestampFormat(format string) LogfmtFormatterOption {
+	return func(f *LogfmtFormatter) {
+		f.TimestampFormat = format
+	}
+}
+
+// WithLogfmtFieldOrder writes the given fields first, in order.
+func WithLogfmtFieldOrder(order []string) LogfmtFormatterOption {
+	return func(f *LogfmtFormatter) {
+		f.FieldOrder = order
+	}
+}
+
+// WithLogfmtDisableTimestamp omits the ts key.
+func WithLogfmtDisableTimestamp() LogfmtFormatterOption {
+	return func(f *LogfmtFormatter) {
+		f.DisableTimestamp = true
+	}
+}
+
+// logfmtReservedKeys are the keys written by the formatter itself
+var logfmtReservedKeys = map[string]bool{"ts": true, "level": true, "msg": true, "caller": true}
+
+// Format implements the Formatter interface for logfmt output
+func (f *LogfmtFormatter) Format(entry *Entry) ([]byte, error) {
+	entry = stripMetrics(entry)
+	var buf bytes.Buffer
+
+	if !f.DisableTimestamp {
+		f.writePair(&buf, "ts", entry.Time.Format(f.TimestampFormat))
+	}
+	f.writePair(&buf, "level", entry.Level.String())
+	f.writePair(&buf, "msg", entry.Message)
+	if entry.Caller != "" {
+		f.writePair(&buf, "caller", entry.Caller)
+	}
+
+	if len(entry.Fields) == 0 {
+		return buf.Bytes(), nil
+	}
+
+	written := make(map[string]bool, len(f.FieldOrder))
+	for _, key := range f.FieldOrder {
+		if value, ok := entry.Fields[key]; ok && !written[key] {
+			f.writeField(&buf, key, value)
+			written[key] = true
+		}
+	}
+
+	keys := make([]string, 0, len(entry.Fields))
+	for key := range entry.Fields {
+		if !written[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		f.writeField(&buf, key, entry.Fields[key])
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeField writes a field, prefixing reserved keys and flattening maps
+func (f *LogfmtFormatter) writeField(buf *bytes.Buffer, key string, value interface{}) {
+	if logfmtReservedKeys[key] {
+		key = "fields." + key
+	}
+	f.writeValue(buf, key, value)
+}
+
+// writeValue writes key=value, recursing into nested maps with dotted keys
+func (f *LogfmtFormatter) writeValue(buf *bytes.Buffer, key string, value interface{}) {
+	var nested map[string]interface{}
+	switch v := value.(type) {
+	case Fields:
+		nested = v
+	case map[string]interface{}:
+		nested = v
+	case map[string]string:
+		nested = make(map[string]interface{}, len(v))
+		for k, s := range v {
+			nested[k] = s
+		}
+	}
+
+	if nested == nil {
+		f.writePair(buf, key, f.stringify(value))
+		return
+	}
+
+	keys := make([]string, 0, len(nested))
+	for k := range nested {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		f.writeValue(buf, key+"."+k, nested[k])
+	}
+}
+
+// writePair writes one key=value pair, separated from the previous one by a space
+func (f *LogfmtFormatter) writePair(buf *bytes.Buffer, key, value string) {
+	if buf.Len() > 0 {
+		buf.WriteByte(' ')
+	}
+	buf.WriteString(logfmtKey(key))
+	buf.WriteByte('=')
+	buf.WriteString(logfmtValue(value))
+}
+
+// stringify renders a field value as a string, with nil and nil pointers
+// as null
+func (f *LogfmtFormatter) stringify(value interface{}) string {
+	if value == nil || isNilPointer(value) {
+		return "null"
+	}
+	if t, ok := value.(time.Time); ok {
+		return t.Format(f.TimestampFormat)
+	}
+	return fieldString(value)
+}
+
+// logfmtKey replaces characters that are not allowed in a logfmt key
+func logfmtKey(key string) string {
+	if key == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError {
+			return '_'
+		}
+		return r
+	}, key)
+}
+
+// logfmtValue quotes a value if it is empty or contains spaces, quotes,
+// '=', control characters or invalid UTF-8
+func logfmtValue(value string) string {
+	if value == "" {
+		return `""`
+	}
+	if !utf8.ValidString(value) {
+		return strconv.Quote(value)
+	}
+	for _, r := range value {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || r == 0x7f {
+			return strconv.Quote(value)
+		}
+	}
+	return value
+}
@@ -69,6 +69,11 @@ type Entry struct {
 	Time    time.Time
 	Caller  string
 	Context context.Context
+
+	// Formatter is the formatter configured on the logger that produced the
+	// entry, or nil if none was set. Handlers without a formatter of their
+	// own use it to render the entry.
+	Formatter Formatter
 }
 
 // Reset resets the entry for reuse in the pool
@@ -79,6 +84,22 @@ func (e *Entry) Reset() {
 	e.Time = time.Time{}
 	e.Caller = ""
 	e.Context = nil
+	e.Formatter = nil
+}
+
+// Clone returns a copy of the entry with its own Fields map.
+//
+// Entries passed to handlers are pooled and reused once Handle returns, so
+// handlers that keep entries around (batching, retries) must clone them.
+func (e *Entry) Clone() *Entry {
+	clone := *e
+	if e.Fields != nil {
+		clone.Fields = make(Fields, len(e.Fields))
+		for k, v := range e.Fields {
+			clone.Fields[k] = v
+		}
+	}
+	return &clone
 }
 
 // Logger is the main logging interface.
@@ -113,6 +134,11 @@ type Logger interface {
 	WithFields(fields Fields) Logger
 	WithContext(ctx context.Context) Logger
 	WithTrace(ctx context.Context) Logger
+
+	// Metric returns a logger whose entries carry the metric in addition to
+	// those already recorded, e.g. for the Embedded Metric Format
+	Metric(name string, value float64, unit string) Logger
+
 	SetLevel(level Level)
 	SetHandler(handler Handler)
 	SetFormatter(formatter Formatter)
@@ -131,6 +157,15 @@ type Formatter interface {
 	Format(entry *Entry) ([]byte, error)
 }
 
+// FormatterAware is implemented by handlers that render entries with a Formatter.
+//
+// A handler whose formatter was set explicitly always uses it. Otherwise it
+// falls back to the logger's formatter (see Entry.Formatter) and finally to
+// its own default.
+type FormatterAware interface {
+	SetFormatter(formatter Formatter)
+}
+
 // Hook is a function that is called for every log entry before it is handled.
 type Hook func(entry *Entry)
 
@@ -182,12 +217,40 @@ func WithCaller(enabled bool) Option {
 }
 
 // WithStacktrace enables stacktrace for error/fatal/panic logs.
+//
+// The stack is captured at the logging call and stored as a StackTrace in
+// the "stacktrace" field.
 func WithStacktrace(enabled bool) Option {
 	return func(l *logger) {
 		l.includeStacktrace = enabled
 	}
 }
 
+// WithStackSkip skips n additional frames above the logging call, for
+// helpers that wrap the logger.
+func WithStackSkip(n int) Option {
+	return func(l *logger) {
+		l.stackSkip = n
+	}
+}
+
+// WithStackFilter sets the filter applied to captured frames. The default,
+// DefaultStackFilter, drops runtime, testing and logging package frames;
+// nil keeps every frame.
+func WithStackFilter(filter StackFilter) Option {
+	return func(l *logger) {
+		l.stackFilter = filter
+	}
+}
+
+// WithStackDepth limits captured stack traces to n frames (0 means no
+// limit).
+func WithStackDepth(n int) Option {
+	return func(l *logger) {
+		l.stackDepth = n
+	}
+}
+
 // Entry pool for reducing allocations
 var entryPool = sync.Pool{
 	New: func() interface{} {
@@ -205,6 +268,11 @@ type logger struct {
 	mu                sync.RWMutex
 	includeCaller     bool
 	includeStacktrace bool
+	stackSkip         int
+	stackFilter       StackFilter
+	stackDepth        int
+	limits            Limits
+	ctx               context.Context
 }
 
 // NewLogger creates a new logger instance with optional configuration.
@@ -218,11 +286,11 @@ type logger struct {
 //	)
 func NewLogger(opts ...Option) Logger {
 	l := &logger{
-		level:     InfoLevel,
-		handler:   NewConsoleHandler(),
-		formatter: NewTextFormatter(),
-		fields:    make(Fields),
-		hooks:     make([]Hook, 0),
+		level:       InfoLevel,
+		handler:     NewConsoleHandler(),
+		fields:      make(Fields),
+		hooks:       make([]Hook, 0),
+		stackFilter: DefaultStackFilter,
 	}
 	for _, opt := range opts {
 		opt(l)
@@ -271,13 +339,17 @@ func (l *logger) WithFields(fields Fields) Logger {
 		newFields[k] = v
 	}
 
-	return &logger{
-		level:     l.level,
-		handler:   l.handler,
-		formatter: l.formatter,
-		fields:    newFields,
-		hooks:     l.hooks,
-	}
+	child := l.clone()
+	child.fields = newFields
+	return child
+}
+
+// Metric returns a new logger with the metric added to its entries
+func (l *logger) Metric(name string, value float64, unit string) Logger {
+	l.mu.RLock()
+	fields := l.fields
+	l.mu.RUnlock()
+	return withMetric(l, fields, Metric{Name: name, Value: value, Unit: unit})
 }
 
 // WithContext returns a new logger with the given context
@@ -285,12 +357,28 @@ func (l *logger) WithContext(ctx context.Context) Logger {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
 
+	child := l.clone()
+	child.ctx = ctx
+	return child
+}
+
+// clone returns a logger with the same settings as l, for the derived
+// loggers of WithFields, WithContext and WithTrace. Every setting must be
+// copied here. It must be called with l.mu held.
+func (l *logger) clone() *logger {
 	return &logger{
-		level:     l.level,
-		handler:   l.handler,
-		formatter: l.formatter,
-		fields:    l.fields,
-		hooks:     l.hooks,
+		level:             l.level,
+		handler:           l.handler,
+		formatter:         l.formatter,
+		fields:            l.fields,
+		hooks:             l.hooks,
+		includeCaller:     l.includeCaller,
+		includeStacktrace: l.includeStacktrace,
+		stackSkip:         l.stackSkip,
+		stackFilter:       l.stackFilter,
+		stackDepth:        l.stackDepth,
+		limits:            l.limits,
+		ctx:               l.ctx,
 	}
 }
 
@@ -327,24 +415,26 @@ func (l *logger) log(level Level, args ...interface{}) {
 	if l.includeCaller {
 		entry.Caller = callerString()
 	}
+	if l.includeStacktrace && (level == ErrorLevel || level == FatalLevel || level == PanicLevel) {
+		l.addStacktrace(entry)
+	}
 
 	l.mu.RLock()
 	handler := l.handler
 	hooks := l.hooks
+	entry.Formatter = l.formatter
+	entry.Context = l.ctx
 	l.mu.RUnlock()
 
 	for _, hook := range hooks {
 		hook(entry)
 	}
+	l.limits.apply(entry)
 
 	if handler != nil {
 		handler.Handle(entry)
 	}
 
-	if l.includeStacktrace && (level == ErrorLevel || level == FatalLevel || level == PanicLevel) {
-		entry.Fields["stacktrace"] = stacktraceString()
-	}
-
 	putEntryToPool(entry)
 }
 
@@ -363,24 +453,26 @@ func (l *logger) logf(level Level, format string, args ...interface{}) {
 	if l.includeCaller {
 		entry.Caller = callerString()
 	}
+	if l.includeStacktrace && (level == ErrorLevel || level == FatalLevel || level == PanicLevel) {
+		l.addStacktrace(entry)
+	}
 
 	l.mu.RLock()
 	handler := l.handler
 	hooks := l.hooks
+	entry.Formatter = l.formatter
+	entry.Context = l.ctx
 	l.mu.RUnlock()
 
 	for _, hook := range hooks {
 		hook(entry)
 	}
+	l.limits.apply(entry)
 
 	if handler != nil {
 		handler.Handle(entry)
 	}
 
-	if l.includeStacktrace && (level == ErrorLevel || level == FatalLevel || level == PanicLevel) {
-		entry.Fields["stacktrace"] = stacktraceString()
-	}
-
 	putEntryToPool(entry)
 }
 
@@ -399,24 +491,26 @@ func (l *logger) logFast(level Level, msg string) {
 	if l.includeCaller {
 		entry.Caller = callerString()
 	}
+	if l.includeStacktrace && (level == ErrorLevel || level == FatalLevel || level == PanicLevel) {
+		l.addStacktrace(entry)
+	}
 
 	l.mu.RLock()
 	handler := l.handler
 	hooks := l.hooks
+	entry.Formatter = l.formatter
+	entry.Context = l.ctx
 	l.mu.RUnlock()
 
 	for _, hook := range hooks {
 		hook(entry)
 	}
+	l.limits.apply(entry)
 
 	if handler != nil {
 		handler.Handle(entry)
 	}
 
-	if l.includeStacktrace && (level == ErrorLevel || level == FatalLevel || level == PanicLevel) {
-		entry.Fields["stacktrace"] = stacktraceString()
-	}
-
 	putEntryToPool(entry)
 }
 
@@ -471,8 +565,13 @@ func callerString() string {
 	return fmt.Sprintf("%s:%d", file, line)
 }
 
-func stacktraceString() string {
-	buf := make([]byte, 2048)
-	n := runtime.Stack(buf, false)
-	return string(buf[:n])
+// addStacktrace stores the stack of the logging call site in the entry.
+// It must be called directly from log, logf or logFast.
+func (l *logger) addStacktrace(entry *Entry) {
+	fields := make(Fields, len(entry.Fields)+1)
+	for k, v := range entry.Fields {
+		fields[k] = v
+	}
+	fields[StacktraceField] = captureStack(3+l.stackSkip, l.stackFilter, l.stackDepth)
+	entry.Fields = fields
 }
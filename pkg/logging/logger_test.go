@@ -1,15 +1,32 @@
 package logging
 
 import (
+	"bufio"
 	"bytes"
+	"compress/zlib"
 	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+	_ "modernc.org/sqlite"
 )
 
 // TestLogger tests basic logging functionality
@@ -214,6 +231,136 @@ func TestRotatingFileHandler(t *testing.T) {
 	}
 }
 
+// TestBufferedFileHandler tests buffered writes and level-triggered flushes
+func TestBufferedFileHandler(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "buffered.log")
+
+	handler, err := NewFileHandler(filename,
+		WithFileBuffer(4096),
+		WithFileFlushInterval(time.Hour),
+		WithFileSync(FileSyncOnError),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create file handler: %v", err)
+	}
+
+	logger := NewLogger(WithHandler(handler))
+	logger.Info("buffered info")
+
+	if content, _ := os.ReadFile(filename); len(content) != 0 {
+		t.Errorf("Expected info entry to stay buffered, file has %q", content)
+	}
+
+	logger.Error("flushed error")
+	content, _ := os.ReadFile(filename)
+	if !strings.Contains(string(content), "buffered info") || !strings.Contains(string(content), "flushed error") {
+		t.Errorf("Expected error entry to flush the buffer, file has %q", content)
+	}
+
+	logger.Info("written on close")
+	if err := handler.(*FileHandler).Close(); err != nil {
+		t.Fatalf("Failed to close file handler: %v", err)
+	}
+	content, _ = os.ReadFile(filename)
+	if !strings.Contains(string(content), "written on close") {
+		t.Errorf("Expected Close to flush the buffer, file has %q", content)
+	}
+}
+
+// TestSharedRotatingFileHandler tests several handlers sharing one rotating file
+func TestSharedRotatingFileHandler(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "shared.log")
+
+	var handlers []Handler
+	for i := 0; i < 3; i++ {
+		handler, err := NewRotatingFileHandler(filename, 2048, 50, WithFileLocking())
+		if err != nil {
+			t.Fatalf("Failed to create rotating file handler: %v", err)
+		}
+		defer handler.(*RotatingFileHandler).Close()
+		handlers = append(handlers, handler)
+	}
+
+	var wg sync.WaitGroup
+	for i, handler := range handlers {
+		wg.Add(1)
+		go func(id int, handler Handler) {
+			defer wg.Done()
+			logger := NewLogger(WithHandler(handler))
+			for j := 0; j < 50; j++ {
+				logger.Infof("writer %d line %d", id, j)
+			}
+		}(i, handler)
+	}
+	wg.Wait()
+
+	files, _ := filepath.Glob(filename + "*")
+	lines := 0
+	for _, name := range files {
+		if strings.HasSuffix(name, ".lock") {
+			continue
+		}
+		content, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("Failed to read log file: %v", err)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+			if !strings.Contains(line, "writer ") {
+				t.Errorf("Unexpected line in %s: %q", name, line)
+			}
+			lines++
+		}
+		if info, _ := os.Stat(name); info.Size() > 2048 {
+			t.Errorf("Expected %s to respect max size, got %d bytes", name, info.Size())
+		}
+	}
+
+	if lines != 150 {
+		t.Errorf("Expected 150 lines across rotated files, got %d", lines)
+	}
+}
+
+// TestFileHandlerReopen tests reopening a file after it was moved away
+func TestFileHandlerReopen(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "app.log")
+
+	handler, err := NewFileHandler(filename, WithFileReopenOnRotate())
+	if err != nil {
+		t.Fatalf("Failed to create file handler: %v", err)
+	}
+	defer handler.(*FileHandler).Close()
+
+	logger := NewLogger(WithHandler(handler))
+	logger.Info("before rotation")
+
+	if err := os.Rename(filename, filename+".1"); err != nil {
+		t.Fatalf("Failed to rename log file: %v", err)
+	}
+
+	logger.Info("after rotation")
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Expected log file to be recreated: %v", err)
+	}
+	if !strings.Contains(string(content), "after rotation") || strings.Contains(string(content), "before rotation") {
+		t.Errorf("Unexpected content after reopen: %s", content)
+	}
+
+	// copytruncate leaves the same file but shorter than our last write
+	fh := handler.(*FileHandler)
+	if fh.guard.changed(filename, fh.file) {
+		t.Error("Expected an unchanged file not to be reopened")
+	}
+	if err := os.Truncate(filename, 0); err != nil {
+		t.Fatalf("Failed to truncate log file: %v", err)
+	}
+	if !fh.guard.changed(filename, fh.file) {
+		t.Error("Expected truncation to be detected")
+	}
+}
+
 // TestHTTPHandler tests HTTP logging
 func TestHTTPHandler(t *testing.T) {
 	// Create test server
@@ -237,209 +384,2098 @@ func TestHTTPHandler(t *testing.T) {
 	// Note: HTTP logging is asynchronous, so we don't check for errors here
 }
 
-// TestAsyncHandler tests async logging
-func TestAsyncHandler(t *testing.T) {
-	var buf bytes.Buffer
-	baseHandler := &testHandler{buf: &buf}
-
-	asyncHandler := NewAsyncHandler(baseHandler, 10, 2)
-	logger := NewLogger()
-	logger.SetHandler(asyncHandler)
+// TestEmailHandler tests digest delivery against a fake SMTP server
+func TestEmailHandler(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	messages := make(chan string, 10)
+	go serveFakeSMTP(listener, messages)
+
+	addr := listener.Addr().(*net.TCPAddr)
+	handler := NewEmailHandler(EmailConfig{
+		Host:             "127.0.0.1",
+		Port:             addr.Port,
+		From:             "alerts@example.com",
+		To:               []string{"ops@example.com"},
+		Subject:          "batch job",
+		DigestInterval:   time.Hour,
+		MaxEmailsPerHour: 1,
+	})
+	defer handler.Close()
+
+	logger := NewLogger(WithHandler(handler))
+	logger.Info("not emailed")
+	logger.Error("first failure")
+	logger.WithFields(Fields{"job": "nightly"}).Error("second failure")
+
+	if err := handler.Flush(); err != nil {
+		t.Fatalf("Failed to flush email handler: %v", err)
+	}
 
-	// Test async logging
-	for i := 0; i < 5; i++ {
-		logger.Info("async message", i)
+	select {
+	case msg := <-messages:
+		if !strings.Contains(msg, "Subject: [ERROR] batch job (2 entries)") {
+			t.Errorf("Unexpected subject in message: %s", msg)
+		}
+		if !strings.Contains(msg, "second failure") || strings.Contains(msg, "not emailed") {
+			t.Errorf("Unexpected digest body: %s", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a digest email")
 	}
 
-	// Give more time for async processing
-	time.Sleep(500 * time.Millisecond)
+	// The hourly cap holds further entries back
+	logger.Error("third failure")
+	handler.Flush()
 
-	// Check if any logs were processed
-	if buf.Len() == 0 {
-		t.Error("Expected async logs to be processed")
+	select {
+	case msg := <-messages:
+		t.Errorf("Expected hourly limit to prevent a second email, got: %s", msg)
+	case <-time.After(100 * time.Millisecond):
 	}
+}
 
-	// Stop the async handler
-	if stopHandler, ok := asyncHandler.(*AsyncHandler); ok {
-		stopHandler.Stop()
+// serveFakeSMTP accepts SMTP sessions and sends each message body to messages
+func serveFakeSMTP(listener net.Listener, messages chan<- string) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func(conn net.Conn) {
+			defer conn.Close()
+			reader := bufio.NewReader(conn)
+			fmt.Fprint(conn, "220 localhost ESMTP\r\n")
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+				case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+					fmt.Fprint(conn, "250-localhost\r\n250 OK\r\n")
+				case cmd == "DATA":
+					fmt.Fprint(conn, "354 go ahead\r\n")
+					var msg strings.Builder
+					for {
+						dataLine, err := reader.ReadString('\n')
+						if err != nil || dataLine == ".\r\n" {
+							break
+						}
+						msg.WriteString(dataLine)
+					}
+					messages <- msg.String()
+					fmt.Fprint(conn, "250 queued\r\n")
+				case cmd == "QUIT":
+					fmt.Fprint(conn, "221 bye\r\n")
+					return
+				default:
+					fmt.Fprint(conn, "250 OK\r\n")
+				}
+			}
+		}(conn)
 	}
 }
 
-// TestSamplingHandler tests sampling logging
-func TestSamplingHandler(t *testing.T) {
-	var buf bytes.Buffer
-	baseHandler := &testHandler{buf: &buf}
+// TestSlackHandler tests grouping and throttling of chat webhook messages
+func TestSlackHandler(t *testing.T) {
+	bodies := make(chan []byte, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies <- body
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
 
-	// Create sampling handler with 20% rate (more reliable for testing)
-	samplingHandler := NewSamplingHandler(baseHandler, 0.2)
-	logger := NewLogger()
-	logger.SetHandler(samplingHandler)
+	handler := NewSlackHandler(WebhookConfig{
+		URL:                  server.URL,
+		GroupWindow:          time.Hour,
+		MaxMessagesPerMinute: 1,
+	})
 
-	// Log many messages with varying lengths to ensure sampling works
-	for i := 0; i < 50; i++ {
-		logger.Info("sampled message", i)
+	logger := NewLogger(WithHandler(handler), WithCaller(true))
+	logger.Info("below threshold")
+	logger.WithFields(Fields{"job": "import"}).Error("import failed")
+	logger.Warn("disk almost full")
+
+	if err := handler.Flush(); err != nil {
+		t.Fatalf("Failed to flush webhook handler: %v", err)
 	}
 
-	// Check that some messages were logged (not all due to sampling)
-	logCount := strings.Count(buf.String(), "sampled message")
-	if logCount == 0 {
-		t.Error("Expected some sampled messages to be logged")
+	var payload struct {
+		Text        string `json:"text"`
+		Attachments []struct {
+			Color  string                   `json:"color"`
+			Blocks []map[string]interface{} `json:"blocks"`
+		} `json:"attachments"`
 	}
-	// With 20% sampling, we expect roughly 10 logs out of 50
-	// Allow some variance but ensure it's not all logs
-	if logCount >= 50 {
-		t.Errorf("Expected sampling to reduce log count, got %d out of 50", logCount)
+	select {
+	case body := <-bodies:
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Fatalf("Failed to parse Slack payload: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a webhook request")
 	}
-}
-
-// TestMultiHandler tests multiple handlers
-func TestMultiHandler(t *testing.T) {
-	var buf1, buf2 bytes.Buffer
-	handler1 := &testHandler{buf: &buf1}
-	handler2 := &testHandler{buf: &buf2}
-
-	multiHandler := NewMultiHandler(handler1, handler2)
 
-	logger := NewLogger()
-	logger.SetHandler(multiHandler)
-
-	logger.Info("multi handler test")
+	if len(payload.Attachments) != 2 {
+		t.Fatalf("Expected 2 grouped entries, got %d: %+v", len(payload.Attachments), payload)
+	}
+	if payload.Attachments[0].Color != LevelColor(ErrorLevel) {
+		t.Errorf("Expected error color, got %s", payload.Attachments[0].Color)
+	}
+	if !strings.Contains(payload.Text, "ERROR") {
+		t.Errorf("Expected summary to use the highest level, got %q", payload.Text)
+	}
 
-	if !strings.Contains(buf1.String(), "multi handler test") {
-		t.Error("First handler should contain the message")
+	// Logged text cannot mention or link
+	body, err := SlackRenderer().Render(NewWebhookMessage([]*Entry{{
+		Level:   ErrorLevel,
+		Message: "<!channel> ping",
+		Fields:  Fields{"link": "<https://example.com|click>"},
+		Caller:  "a&b.go:1",
+	}}, 0))
+	if err != nil {
+		t.Fatalf("Failed to render Slack message: %v", err)
+	}
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		t.Fatalf("Failed to parse Slack payload: %v", err)
+	}
+	text := fmt.Sprint(doc)
+	if strings.Contains(text, "<!channel>") || strings.Contains(text, "<https") ||
+		!strings.Contains(text, "&lt;!channel&gt; ping") || !strings.Contains(text, "a&amp;b.go:1") {
+		t.Errorf("Expected escaped Slack text, got %s", text)
 	}
 
-	if !strings.Contains(buf2.String(), "multi handler test") {
-		t.Error("Second handler should contain the message")
+	// Throttled messages are held back
+	logger.Error("throttled")
+	handler.Flush()
+
+	select {
+	case body := <-bodies:
+		t.Errorf("Expected throttling to suppress the message, got: %s", body)
+	case <-time.After(100 * time.Millisecond):
 	}
 }
 
-// TestTextFormatter tests text formatting
-func TestTextFormatter(t *testing.T) {
-	formatter := NewTextFormatter()
+// TestDiscordHandler tests that large groups are split into messages of
+// at most 10 embeds and that failed messages are kept for the next flush
+func TestDiscordHandler(t *testing.T) {
+	var fail atomic.Bool
+	fail.Store(true)
+	embeds := make(chan int, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var payload struct {
+			Embeds []map[string]interface{} `json:"embeds"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("Failed to parse Discord payload: %v", err)
+		}
+		embeds <- len(payload.Embeds)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
 
-	entry := &Entry{
-		Level:   InfoLevel,
-		Message: "test message",
-		Time:    time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC),
-		Fields: Fields{
-			"key": "value",
-		},
+	handler := NewDiscordHandler(WebhookConfig{
+		URL:                  server.URL,
+		GroupWindow:          time.Hour,
+		MaxEntriesPerMessage: 50,
+	})
+	for i := 0; i < 12; i++ {
+		handler.Handle(&Entry{Level: ErrorLevel, Message: fmt.Sprintf("entry %d", i), Time: time.Now()})
 	}
 
-	formatted, err := formatter.Format(entry)
-	if err != nil {
-		t.Fatalf("Failed to format entry: %v", err)
+	if err := handler.Flush(); err == nil {
+		t.Fatal("Expected the failed request to be reported")
 	}
 
-	formattedStr := string(formatted)
-	if !strings.Contains(formattedStr, "test message") {
-		t.Errorf("Expected formatted string to contain 'test message', got: %s", formattedStr)
+	fail.Store(false)
+	if err := handler.Flush(); err != nil {
+		t.Fatalf("Failed to flush kept entries: %v", err)
 	}
+	close(embeds)
 
-	if !strings.Contains(formattedStr, "key=value") {
-		t.Errorf("Expected formatted string to contain 'key=value', got: %s", formattedStr)
+	var counts []int
+	for n := range embeds {
+		counts = append(counts, n)
+	}
+	if len(counts) != 2 || counts[0] != 10 || counts[1] != 2 {
+		t.Errorf("Expected messages of 10 and 2 embeds, got %v", counts)
 	}
-}
-
-// TestJSONFormatter tests JSON formatting
-func TestJSONFormatter(t *testing.T) {
-	formatter := NewJSONFormatter()
 
-	entry := &Entry{
-		Level:   InfoLevel,
-		Message: "test message",
-		Time:    time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC),
-		Fields: Fields{
-			"key": "value",
-		},
+	// The renderer refuses to drop embeds
+	entries := make([]*Entry, 11)
+	for i := range entries {
+		entries[i] = &Entry{Level: WarnLevel}
+	}
+	if _, err := DiscordRenderer().Render(NewWebhookMessage(entries, 0)); err == nil {
+		t.Error("Expected an error rendering more than 10 embeds")
 	}
 
-	formatted, err := formatter.Format(entry)
+	// Text is cut to Discord's limits and empty values are replaced
+	fields := Fields{"empty": ""}
+	for i := 0; i < 30; i++ {
+		fields[fmt.Sprintf("f%02d", i)] = strings.Repeat("v", 2000)
+	}
+	big := &Entry{Level: ErrorLevel, Message: strings.Repeat("m", 300), Fields: fields, Time: time.Now()}
+	long := &Entry{Level: ErrorLevel, Message: strings.Repeat("m", 5000), Time: time.Now()}
+	body, err := DiscordRenderer().Render(NewWebhookMessage([]*Entry{big, big, long}, 0))
 	if err != nil {
-		t.Fatalf("Failed to format entry: %v", err)
+		t.Fatalf("Failed to render large entries: %v", err)
 	}
-
-	var logEntry map[string]interface{}
-	if err := json.Unmarshal(formatted, &logEntry); err != nil {
-		t.Fatalf("Failed to parse JSON: %v", err)
+	var payload struct {
+		Embeds []struct {
+			Title       string `json:"title"`
+			Description string `json:"description"`
+			Fields      []struct {
+				Name  string `json:"name"`
+				Value string `json:"value"`
+			} `json:"fields"`
+		} `json:"embeds"`
 	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("Failed to parse Discord payload: %v", err)
+	}
+	total := 0
+	for _, embed := range payload.Embeds {
+		total += utf8.RuneCountInString(embed.Title) + utf8.RuneCountInString(embed.Description)
+		if utf8.RuneCountInString(embed.Description) > 4096 || len(embed.Fields) > 25 {
+			t.Errorf("Embed exceeds Discord limits: %d description characters, %d fields", utf8.RuneCountInString(embed.Description), len(embed.Fields))
+		}
+		for _, f := range embed.Fields {
+			total += utf8.RuneCountInString(f.Name) + utf8.RuneCountInString(f.Value)
+			if f.Value == "" || utf8.RuneCountInString(f.Value) > 1024 {
+				t.Errorf("Invalid field value length %d for %s", utf8.RuneCountInString(f.Value), f.Name)
+			}
+		}
+	}
+	if total > 6000 {
+		t.Errorf("Expected at most 6000 characters across embeds, got %d", total)
+	}
+	if len(payload.Embeds) != 3 || len(payload.Embeds[0].Fields) == 0 || payload.Embeds[0].Fields[0].Value != "-" {
+		t.Errorf("Expected the empty field to be kept as \"-\": %+v", payload.Embeds)
+	}
+}
 
-	if logEntry["message"] != "test message" {
-		t.Errorf("Expected message to be 'test message', got: %v", logEntry["message"])
+// TestTemplateRenderer tests custom webhook payload templates
+func TestTemplateRenderer(t *testing.T) {
+	renderer, err := NewTemplateRenderer(`{"text": {{ (index .Entries 0).Message | json }}, "color": "{{ .Color }}"}`)
+	if err != nil {
+		t.Fatalf("Failed to parse template: %v", err)
 	}
 
-	if logEntry["level"] != "info" {
-		t.Errorf("Expected level to be 'info', got: %v", logEntry["level"])
+	body, err := renderer.Render(NewWebhookMessage([]*Entry{{Level: WarnLevel, Message: `say "hi"`}}, 0))
+	if err != nil {
+		t.Fatalf("Failed to render template: %v", err)
 	}
 
-	if logEntry["key"] != "value" {
-		t.Errorf("Expected key to be 'value', got: %v", logEntry["key"])
+	var payload map[string]string
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("Expected valid JSON, got %s: %v", body, err)
+	}
+	if payload["text"] != `say "hi"` || payload["color"] != LevelColor(WarnLevel) {
+		t.Errorf("Unexpected rendered payload: %v", payload)
 	}
 }
 
-// testHandler is a test handler that writes to a buffer
-type testHandler struct {
-	buf       *bytes.Buffer
-	formatter Formatter
+// recordingSQLDriver is a database/sql driver that records executed statements
+type recordingSQLDriver struct {
+	mu    sync.Mutex
+	execs []recordedExec
 }
 
-func (h *testHandler) Handle(entry *Entry) error {
-	formatter := h.formatter
-	if formatter == nil {
-		formatter = NewTextFormatter()
-	}
+type recordedExec struct {
+	query string
+	args  []driver.NamedValue
+}
 
-	formatted, err := formatter.Format(entry)
-	if err != nil {
-		return err
-	}
+func (d *recordingSQLDriver) Open(string) (driver.Conn, error) { return recordingSQLConn{d}, nil }
 
-	_, err = h.buf.Write(formatted)
-	return err
+// Connect and Driver implement driver.Connector, so the recorder can be
+// used with sql.OpenDB without registering a driver name
+func (d *recordingSQLDriver) Connect(context.Context) (driver.Conn, error) {
+	return recordingSQLConn{d}, nil
 }
+func (d *recordingSQLDriver) Driver() driver.Driver { return d }
 
-// setFormatter sets the formatter for the test handler
-func (h *testHandler) setFormatter(formatter Formatter) {
-	h.formatter = formatter
+func (d *recordingSQLDriver) statements() []recordedExec {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]recordedExec(nil), d.execs...)
 }
 
-// BenchmarkLogger benchmarks the logger performance
-func BenchmarkLogger(b *testing.B) {
-	logger := NewLogger()
-	logger.SetLevel(InfoLevel)
+type recordingSQLConn struct{ d *recordingSQLDriver }
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		logger.Info("benchmark message")
-	}
+func (c recordingSQLConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+func (c recordingSQLConn) Close() error              { return nil }
+func (c recordingSQLConn) Begin() (driver.Tx, error) { return nil, errors.New("tx not supported") }
+
+func (c recordingSQLConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.d.execs = append(c.d.execs, recordedExec{query, args})
+	return driver.RowsAffected(0), nil
 }
 
-// BenchmarkFastLogger benchmarks the fast logger performance
-func BenchmarkFastLogger(b *testing.B) {
+// TestSQLHandler tests schema creation, batched inserts and purges
+func TestSQLHandler(t *testing.T) {
+	recorder := &recordingSQLDriver{}
+	db := sql.OpenDB(recorder)
+	defer db.Close()
+
+	if _, err := NewSQLHandler(SQLConfig{DB: db, Dialect: SQLDialectPostgres, Table: "logs; DROP"}); err == nil {
+		t.Error("Expected invalid table name to be rejected")
+	}
+
+	handler, err := NewSQLHandler(SQLConfig{
+		DB:        db,
+		Dialect:   SQLDialectPostgres,
+		Table:     "app_logs",
+		Retention: 24 * time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create SQL handler: %v", err)
+	}
+
 	logger := NewLogger()
-	logger.SetLevel(InfoLevel)
+	logger.SetHandler(handler)
+	logger.WithFields(Fields{"user": "alice", "err": errors.New("boom")}).Error("first")
+	logger.Info("second")
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		logger.InfoFast("benchmark fast message")
+	if err := handler.Close(); err != nil {
+		t.Fatalf("Failed to flush: %v", err)
+	}
+	if _, err := handler.Purge(); err != nil {
+		t.Fatalf("Failed to purge: %v", err)
+	}
+
+	execs := recorder.statements()
+	if len(execs) != 4 {
+		t.Fatalf("Expected schema, index, insert and purge statements, got %d", len(execs))
+	}
+	if !strings.Contains(execs[0].query, `CREATE TABLE IF NOT EXISTS "app_logs"`) || !strings.Contains(execs[0].query, "JSONB") {
+		t.Errorf("Unexpected schema statement: %s", execs[0].query)
+	}
+
+	insert := execs[2]
+	if !strings.Contains(insert.query, "($7, $8, $9, $10, $11, $12)") || len(insert.args) != 12 {
+		t.Errorf("Expected one two-row insert, got %s with %d args", insert.query, len(insert.args))
+	}
+
+	var fields map[string]string
+	if err := json.Unmarshal([]byte(insert.args[5].Value.(string)), &fields); err != nil {
+		t.Fatalf("Expected JSON fields column: %v", err)
+	}
+	if fields["user"] != "alice" || fields["err"] != "boom" {
+		t.Errorf("Unexpected fields column: %v", fields)
+	}
+
+	if !strings.HasPrefix(execs[3].query, `DELETE FROM "app_logs" WHERE logged_at < $1`) {
+		t.Errorf("Unexpected purge statement: %s", execs[3].query)
 	}
 }
 
-// BenchmarkLoggerWithFields benchmarks logging with fields
-func BenchmarkLoggerWithFields(b *testing.B) {
-	logger := NewLogger()
-	logger.SetLevel(InfoLevel)
+// TestSQLHandlerSQLite runs the generated schema, inserts and purge against
+// an embedded SQLite database
+func TestSQLHandlerSQLite(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "logs.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	handler, err := NewSQLHandler(SQLConfig{
+		DB:        db,
+		Dialect:   SQLDialectSQLite,
+		Table:     "app_logs",
+		BatchSize: 2,
+		Retention: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create SQL handler: %v", err)
+	}
 
-	fields := Fields{
-		"user_id": 123,
-		"action":  "benchmark",
-		"count":   b.N,
+	logger := NewLogger(WithHandler(handler), WithCaller(true))
+	logger.WithFields(Fields{"user": "alice", "err": errors.New("boom")}).Error("first")
+	logger.Info("second")
+	handler.Handle(&Entry{Level: WarnLevel, Message: "stale", Time: time.Now().Add(-2 * time.Hour)})
+	if err := handler.Close(); err != nil {
+		t.Fatalf("Failed to flush: %v", err)
 	}
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		logger.WithFields(fields).Info("benchmark message with fields")
+	// The schema is idempotent
+	if _, err := NewSQLHandler(SQLConfig{DB: db, Dialect: SQLDialectSQLite, Table: "app_logs"}); err != nil {
+		t.Fatalf("Failed to reuse existing table: %v", err)
+	}
+
+	if purged, err := handler.Purge(); err != nil || purged != 1 {
+		t.Fatalf("Expected the stale row to be purged, got %d (%v)", purged, err)
+	}
+
+	rows, err := db.Query("SELECT level, level_value, message, caller, fields FROM app_logs ORDER BY id")
+	if err != nil {
+		t.Fatalf("Failed to query logs: %v", err)
+	}
+	defer rows.Close()
+
+	type row struct {
+		level, message, caller, fields string
+		value                          int
+	}
+	var got []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.level, &r.value, &r.message, &r.caller, &r.fields); err != nil {
+			t.Fatalf("Failed to scan row: %v", err)
+		}
+		got = append(got, r)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 rows after purge, got %+v", got)
+	}
+	if got[0].level != "error" || got[0].value != 40 || got[0].message != "first" || !strings.Contains(got[0].caller, "logger_test.go:") {
+		t.Errorf("Unexpected first row: %+v", got[0])
+	}
+	var fields map[string]string
+	if err := json.Unmarshal([]byte(got[0].fields), &fields); err != nil || fields["user"] != "alice" || fields["err"] != "boom" {
+		t.Errorf("Unexpected fields column %q: %v", got[0].fields, err)
+	}
+	if got[1].level != "info" || got[1].message != "second" {
+		t.Errorf("Unexpected second row: %+v", got[1])
+	}
+
+	// A failed insert keeps the batch for the next flush
+	pending, err := NewSQLHandler(SQLConfig{DB: db, Dialect: SQLDialectSQLite, Table: "late_logs", SkipSchema: true})
+	if err != nil {
+		t.Fatalf("Failed to create SQL handler: %v", err)
+	}
+	defer pending.Close()
+	pending.Handle(&Entry{Level: InfoLevel, Message: "kept", Time: time.Now()})
+	if err := pending.Flush(); err == nil {
+		t.Fatal("Expected the insert into a missing table to fail")
+	}
+	if _, err := NewSQLHandler(SQLConfig{DB: db, Dialect: SQLDialectSQLite, Table: "late_logs"}); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	if err := pending.Flush(); err != nil {
+		t.Fatalf("Failed to flush kept entries: %v", err)
+	}
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM late_logs WHERE message = 'kept'").Scan(&count); err != nil || count != 1 || pending.Dropped() != 0 {
+		t.Errorf("Expected the kept entry to be inserted, got %d rows (%v), %d dropped", count, err, pending.Dropped())
+	}
+}
+
+// TestGELFHandler tests chunked, compressed UDP and null-delimited TCP output
+func TestGELFHandler(t *testing.T) {
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen on UDP: %v", err)
+	}
+	defer udp.Close()
+
+	handler, err := NewGELFHandler(GELFConfig{
+		Address:          udp.LocalAddr().String(),
+		Compression:      GELFCompressZlib,
+		ChunkSize:        64,
+		FormatterOptions: []GELFFormatterOption{WithGELFHost("web-1")},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create GELF handler: %v", err)
+	}
+	defer handler.Close()
+
+	logger := NewLogger(WithHandler(handler))
+	logger.WithFields(Fields{"user": Fields{"id": 7}, "stacktrace": "main.go:12"}).Error(strings.Repeat("disk full ", 20))
+
+	var chunks [][]byte
+	udp.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		buf := make([]byte, 128)
+		n, _, err := udp.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("Failed to read chunk: %v", err)
+		}
+		chunk := buf[:n]
+		if n > 64 || chunk[0] != 0x1e || chunk[1] != 0x0f {
+			t.Fatalf("Invalid GELF chunk of %d bytes", n)
+		}
+		chunks = append(chunks, chunk)
+		if int(chunk[11]) == len(chunks) {
+			break
+		}
+	}
+
+	var compressed []byte
+	for i, chunk := range chunks {
+		if int(chunk[10]) != i || !bytes.Equal(chunk[2:10], chunks[0][2:10]) {
+			t.Fatalf("Unexpected chunk header % x", chunk[:12])
+		}
+		compressed = append(compressed, chunk[12:]...)
+	}
+	r, err := zlib.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatalf("Expected zlib payload: %v", err)
+	}
+	payload, _ := io.ReadAll(r)
+
+	var msg map[string]interface{}
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		t.Fatalf("Expected GELF JSON: %v", err)
+	}
+	if msg["version"] != "1.1" || msg["host"] != "web-1" || msg["level"] != float64(3) || msg["_user_id"] != float64(7) {
+		t.Errorf("Unexpected GELF message: %v", msg)
+	}
+	if full, _ := msg["full_message"].(string); !strings.HasSuffix(full, "main.go:12") {
+		t.Errorf("Expected stack trace in full_message, got %q", full)
+	}
+
+	// TCP messages are uncompressed and null-terminated
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen on TCP: %v", err)
+	}
+	defer tcp.Close()
+
+	tcpHandler, err := NewGELFHandler(GELFConfig{Address: tcp.Addr().String(), Protocol: "tcp"})
+	if err != nil {
+		t.Fatalf("Failed to create GELF TCP handler: %v", err)
+	}
+	defer tcpHandler.Close()
+
+	conn, err := tcp.Accept()
+	if err != nil {
+		t.Fatalf("Failed to accept: %v", err)
+	}
+	defer conn.Close()
+
+	NewLogger(WithHandler(tcpHandler)).Info("over tcp")
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	frame, err := bufio.NewReader(conn).ReadBytes(0)
+	if err != nil {
+		t.Fatalf("Failed to read TCP frame: %v", err)
+	}
+	if err := json.Unmarshal(frame[:len(frame)-1], &msg); err != nil || msg["short_message"] != "over tcp" {
+		t.Errorf("Unexpected TCP frame %q: %v", frame, err)
+	}
+}
+
+// TestFormattersNilPointerFields tests that nil pointer errors and
+// Stringers in fields do not crash formatters
+func TestFormattersNilPointerFields(t *testing.T) {
+	var nilErr *os.PathError
+	var nilURL *url.URL
+	entry := &Entry{
+		Level:   ErrorLevel,
+		Message: "nil fields",
+		Time:    time.Now(),
+		Fields:  Fields{"error": error(nilErr), "cause": error(nilErr), "url": nilURL},
+	}
+
+	pattern, err := NewPatternFormatter("%msg %X{error} %fields")
+	if err != nil {
+		t.Fatal(err)
+	}
+	patternJSON, err := NewPatternFormatter("%msg %fields{json}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	formatters := map[string]Formatter{
+		"pattern":      pattern,
+		"pattern-json": patternJSON,
+		"gelf":         NewGELFFormatter(),
+		"ecs":          NewECSFormatter(),
+		"gcp":          NewGCPFormatter(),
+		"datadog":      NewDatadogFormatter(),
+		"emf":          NewEMFFormatter(),
+		"cef":          NewCEFFormatter(),
+		"msgpack":      NewMsgpackFormatter(),
+		"cbor":         NewCBORFormatter(),
+		"dev":          NewDevFormatter(),
+		"leef":         NewLEEFFormatter(),
+	}
+	for name, formatter := range formatters {
+		formatted, err := formatter.Format(entry)
+		if err != nil || len(formatted) == 0 {
+			t.Errorf("%s: failed to format nil fields: %v", name, err)
+		}
+	}
+}
+
+// TestOTLPHandler tests OTLP/JSON and OTLP/protobuf exports
+func TestOTLPHandler(t *testing.T) {
+	var mu sync.Mutex
+	var contentTypes []string
+	var bodies [][]byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		contentTypes = append(contentTypes, r.Header.Get("Content-Type"))
+		bodies = append(bodies, body)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	tracer := NewOTelTracer("checkout")
+	ctx, span := tracer.StartSpan(context.Background(), "pay")
+
+	for _, protocol := range []string{OTLPProtocolJSON, OTLPProtocolProtobuf} {
+		handler := NewOTLPHandler(OTLPConfig{
+			OTel:     OTelConfig{ServiceName: "checkout", ServiceVersion: "1.2.0"},
+			Endpoint: server.URL,
+			Protocol: protocol,
+		})
+
+		logger := NewLogger()
+		logger.SetHandler(handler)
+		logger.WithContext(ctx).WithFields(Fields{"order_id": 42}).Error("payment failed")
+
+		if err := handler.Close(); err != nil {
+			t.Fatalf("Failed to export %s: %v", protocol, err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) != 2 {
+		t.Fatalf("Expected 2 exports, got %d", len(bodies))
+	}
+	if contentTypes[0] != "application/json" || contentTypes[1] != "application/x-protobuf" {
+		t.Errorf("Unexpected content types: %v", contentTypes)
+	}
+
+	var payload struct {
+		ResourceLogs []struct {
+			Resource struct {
+				Attributes []struct {
+					Key   string                 `json:"key"`
+					Value map[string]interface{} `json:"value"`
+				} `json:"attributes"`
+			} `json:"resource"`
+			ScopeLogs []struct {
+				LogRecords []struct {
+					SeverityNumber int                    `json:"severityNumber"`
+					SeverityText   string                 `json:"severityText"`
+					Body           map[string]interface{} `json:"body"`
+					TraceID        string                 `json:"traceId"`
+					SpanID         string                 `json:"spanId"`
+				} `json:"logRecords"`
+			} `json:"scopeLogs"`
+		} `json:"resourceLogs"`
+	}
+	if err := json.Unmarshal(bodies[0], &payload); err != nil {
+		t.Fatalf("Expected valid OTLP/JSON: %v", err)
+	}
+
+	resource := payload.ResourceLogs[0].Resource.Attributes
+	if len(resource) != 2 || resource[0].Key != "service.name" || resource[0].Value["stringValue"] != "checkout" {
+		t.Errorf("Unexpected resource attributes: %+v", resource)
+	}
+
+	record := payload.ResourceLogs[0].ScopeLogs[0].LogRecords[0]
+	if record.SeverityNumber != 17 || record.SeverityText != "ERROR" || record.Body["stringValue"] != "payment failed" {
+		t.Errorf("Unexpected log record: %+v", record)
+	}
+	if record.TraceID != span.TraceID || record.SpanID != span.SpanID {
+		t.Errorf("Expected trace %s/%s, got %s/%s", span.TraceID, span.SpanID, record.TraceID, record.SpanID)
+	}
+
+	if !bytes.Contains(bodies[1], []byte("payment failed")) || !bytes.Contains(bodies[1], []byte("order_id")) {
+		t.Error("Expected protobuf export to contain the record")
+	}
+
+	// Retryable failures keep the batch; other failures count it as dropped
+	var status atomic.Int32
+	var received atomic.Int32
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer failing.Close()
+
+	handler := NewOTLPHandler(OTLPConfig{Endpoint: failing.URL, FlushInterval: time.Hour})
+	defer handler.Close()
+	handler.Handle(&Entry{Level: InfoLevel, Message: "kept", Time: time.Now()})
+
+	status.Store(http.StatusServiceUnavailable)
+	if err := handler.Flush(); err == nil || handler.Dropped() != 0 {
+		t.Fatalf("Expected a retryable failure without drops, got %v and %d dropped", err, handler.Dropped())
+	}
+	status.Store(http.StatusBadRequest)
+	if err := handler.Flush(); err == nil || handler.Dropped() != 1 {
+		t.Fatalf("Expected the kept batch to be retried and then dropped, got %v and %d dropped", err, handler.Dropped())
+	}
+	if err := handler.Flush(); err != nil || received.Load() != 2 {
+		t.Errorf("Expected an empty queue after the drop, got %v after %d requests", err, received.Load())
+	}
+}
+
+// TestAsyncHandler tests async logging
+func TestAsyncHandler(t *testing.T) {
+	var buf bytes.Buffer
+	baseHandler := &testHandler{buf: &buf}
+
+	asyncHandler := NewAsyncHandler(baseHandler, 10, 2)
+	logger := NewLogger()
+	logger.SetHandler(asyncHandler)
+
+	// Test async logging
+	for i := 0; i < 5; i++ {
+		logger.Info("async message", i)
+	}
+
+	// Give more time for async processing
+	time.Sleep(500 * time.Millisecond)
+
+	// Stop the async handler before reading what the workers wrote
+	if stopHandler, ok := asyncHandler.(*AsyncHandler); ok {
+		stopHandler.Stop()
+	}
+
+	// Check if any logs were processed
+	if buf.Len() == 0 {
+		t.Error("Expected async logs to be processed")
+	}
+}
+
+// TestSamplingHandler tests sampling logging
+func TestSamplingHandler(t *testing.T) {
+	var buf bytes.Buffer
+	baseHandler := &testHandler{buf: &buf}
+
+	// Create sampling handler with 20% rate (more reliable for testing)
+	samplingHandler := NewSamplingHandler(baseHandler, 0.2)
+	logger := NewLogger()
+	logger.SetHandler(samplingHandler)
+
+	// Log many messages with varying lengths to ensure sampling works
+	for i := 0; i < 50; i++ {
+		logger.Info("sampled message", i)
+	}
+
+	// Check that some messages were logged (not all due to sampling)
+	logCount := strings.Count(buf.String(), "sampled message")
+	if logCount == 0 {
+		t.Error("Expected some sampled messages to be logged")
+	}
+	// With 20% sampling, we expect roughly 10 logs out of 50
+	// Allow some variance but ensure it's not all logs
+	if logCount >= 50 {
+		t.Errorf("Expected sampling to reduce log count, got %d out of 50", logCount)
+	}
+}
+
+// TestMultiHandler tests multiple handlers
+func TestMultiHandler(t *testing.T) {
+	var buf1, buf2 bytes.Buffer
+	handler1 := &testHandler{buf: &buf1}
+	handler2 := &testHandler{buf: &buf2}
+
+	multiHandler := NewMultiHandler(handler1, handler2)
+
+	logger := NewLogger()
+	logger.SetHandler(multiHandler)
+
+	logger.Info("multi handler test")
+
+	if !strings.Contains(buf1.String(), "multi handler test") {
+		t.Error("First handler should contain the message")
+	}
+
+	if !strings.Contains(buf2.String(), "multi handler test") {
+		t.Error("Second handler should contain the message")
+	}
+}
+
+// TestParallelMultiHandler tests concurrent fan-out, child levels and timeouts
+func TestParallelMultiHandler(t *testing.T) {
+	var debugBuf, errorBuf bytes.Buffer
+	release := make(chan struct{})
+	defer close(release)
+
+	slow := handlerFunc(func(*Entry) error {
+		<-release
+		return nil
+	})
+	failing := handlerFunc(func(*Entry) error {
+		return errors.New("connection refused")
+	})
+
+	multi := NewParallelMultiHandler(0,
+		NewChildHandler(&testHandler{buf: &debugBuf}, WithChildName("file")),
+		NewChildHandler(&testHandler{buf: &errorBuf}, WithChildMinLevel(ErrorLevel)),
+		NewChildHandler(slow, WithChildName("http"), WithChildTimeout(20*time.Millisecond)),
+		NewChildHandler(failing, WithChildName("syslog"), WithChildMinLevel(ErrorLevel)),
+	)
+
+	logger := NewLogger(WithHandler(multi), WithLevel(DebugLevel))
+	logger.Debug("debug only")
+
+	start := time.Now()
+	err := multi.Handle(&Entry{Level: ErrorLevel, Message: "to everyone", Time: time.Now()})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected slow handler to be abandoned, Handle took %v", elapsed)
+	}
+
+	if !strings.Contains(debugBuf.String(), "debug only") || strings.Contains(errorBuf.String(), "debug only") {
+		t.Error("Expected debug entry only in the debug handler")
+	}
+	if !strings.Contains(errorBuf.String(), "to everyone") {
+		t.Error("Expected error entry in the error handler")
+	}
+
+	if !errors.Is(err, ErrHandlerTimeout) {
+		t.Errorf("Expected timeout error, got %v", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "handler http") || !strings.Contains(err.Error(), "handler syslog: connection refused") {
+		t.Errorf("Expected errors from every failing handler, got %v", err)
+	}
+
+	// Children run concurrently on their own copies of the entry
+	rewrite := handlerFunc(func(e *Entry) error {
+		e.Fields = Fields{"rewritten": true}
+		e.Message = "changed"
+		return nil
+	})
+	entry := &Entry{Level: InfoLevel, Message: "original", Fields: Fields{"id": 1}}
+	if err := NewParallelMultiHandler(0, rewrite, rewrite).Handle(entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Message != "original" || len(entry.Fields) != 1 {
+		t.Errorf("Expected the logger's entry to be untouched, got %+v", entry)
+	}
+}
+
+// TestRetryHandler tests retries, error classification and dead-lettering
+func TestRetryHandler(t *testing.T) {
+	var calls int
+	statuses := []int{503, 503, 0, 400}
+	flaky := handlerFunc(func(*Entry) error {
+		status := statuses[calls]
+		calls++
+		if status == 0 {
+			return nil
+		}
+		return &HTTPStatusError{StatusCode: status}
+	})
+
+	deadLetterFile := filepath.Join(t.TempDir(), "dead.jsonl")
+	deadLetter, err := NewDeadLetterFileHandler(deadLetterFile)
+	if err != nil {
+		t.Fatalf("Failed to create dead-letter handler: %v", err)
+	}
+	defer deadLetter.(*FileHandler).Close()
+
+	retry := NewRetryHandler(flaky, RetryConfig{
+		MaxAttempts:    5,
+		InitialBackoff: time.Millisecond,
+		Jitter:         0.5,
+		DeadLetter:     deadLetter,
+	})
+
+	logger := NewLogger(WithHandler(retry))
+	logger.Info("retried until delivered")
+	logger.Info("rejected by the server")
+
+	if calls != 4 {
+		t.Errorf("Expected 3 attempts and 1 non-retried attempt, got %d calls", calls)
+	}
+	if stats := retry.Stats(); stats.Handled != 1 || stats.Retries != 2 || stats.DeadLettered != 1 {
+		t.Errorf("Unexpected retry stats: %+v", stats)
+	}
+
+	content, err := os.ReadFile(deadLetterFile)
+	if err != nil {
+		t.Fatalf("Failed to read dead-letter file: %v", err)
+	}
+	var dead map[string]interface{}
+	if err := json.Unmarshal(bytes.TrimSpace(content), &dead); err != nil {
+		t.Fatalf("Expected one JSON line, got %q: %v", content, err)
+	}
+	if dead["message"] != "rejected by the server" || dead["dead_letter_attempts"] != float64(1) {
+		t.Errorf("Unexpected dead-letter entry: %v", dead)
+	}
+
+	// An open circuit is not retried
+	breaker := NewCircuitBreaker(1, time.Minute)
+	failing := handlerFunc(func(*Entry) error { return errors.New("connection refused") })
+	guarded := NewRetryHandler(NewCircuitBreakerHandler(failing, breaker, NewLogger(WithHandler(&testHandler{buf: &bytes.Buffer{}}))), RetryConfig{
+		MaxAttempts:    10,
+		InitialBackoff: time.Millisecond,
+	})
+	err = guarded.Handle(&Entry{Level: ErrorLevel, Message: "down"})
+	if !errors.Is(err, ErrCircuitOpen) || guarded.Stats().Retries != 1 {
+		t.Errorf("Expected retries to stop once the circuit opened, got %v after %d retries", err, guarded.Stats().Retries)
+	}
+
+	// Backoff ends when the entry's context is cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	slow := NewRetryHandler(failing, RetryConfig{MaxAttempts: 3, InitialBackoff: time.Minute})
+	start := time.Now()
+	if err := slow.Handle(&Entry{Level: ErrorLevel, Message: "down", Context: ctx}); err == nil {
+		t.Error("Expected an error once the context was done")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected backoff to stop with the context, took %v", elapsed)
+	}
+
+	// AsyncHandler queues copies, so retries never see a reused entry
+	var mu sync.Mutex
+	var messages []string
+	record := handlerFunc(func(e *Entry) error {
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		messages = append(messages, e.Message)
+		mu.Unlock()
+		return nil
+	})
+	async := NewAsyncHandler(record, 10, 1).(*AsyncHandler)
+	entry := &Entry{Level: InfoLevel, Message: "queued"}
+	async.Handle(entry)
+	entry.Reset()
+	time.Sleep(20 * time.Millisecond)
+	async.Stop()
+	mu.Lock()
+	defer mu.Unlock()
+	if len(messages) != 1 || messages[0] != "queued" {
+		t.Errorf("Expected the queued copy to be handled, got %q", messages)
+	}
+}
+
+// TestWriterHandler tests logging to an arbitrary writer
+func TestWriterHandler(t *testing.T) {
+	// fatih/color disables colors globally when stdout is not a terminal, as
+	// under go test; enable them so the handler's own detection is checked
+	noColor := color.NoColor
+	color.NoColor = false
+	defer func() { color.NoColor = noColor }()
+
+	var buf bytes.Buffer
+	handler := NewWriterHandler(&buf, WithWriterBuffer(4096))
+
+	logger := NewLogger()
+	logger.SetHandler(handler)
+
+	logger.Info("buffered message")
+	if buf.Len() != 0 {
+		t.Errorf("Expected output to be buffered until flush, got: %s", buf.String())
+	}
+
+	if err := handler.Sync(); err != nil {
+		t.Fatalf("Failed to sync writer handler: %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "buffered message") {
+		t.Errorf("Expected output to contain 'buffered message', got: %s", buf.String())
+	}
+
+	if strings.Contains(buf.String(), "\x1b[") {
+		t.Errorf("Expected no color codes for non-terminal output, got: %q", buf.String())
+	}
+}
+
+// TestSplitConsoleHandler tests level-split console output
+func TestSplitConsoleHandler(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	handler := NewSplitConsoleHandler(WithConsoleWriters(&stdout, &stderr))
+
+	logger := NewLogger()
+	logger.SetHandler(handler)
+
+	logger.Info("to stdout")
+	logger.Warn("to stderr")
+
+	if !strings.Contains(stdout.String(), "to stdout") || strings.Contains(stdout.String(), "to stderr") {
+		t.Errorf("Unexpected stdout content: %s", stdout.String())
+	}
+
+	if !strings.Contains(stderr.String(), "to stderr") || strings.Contains(stderr.String(), "to stdout") {
+		t.Errorf("Unexpected stderr content: %s", stderr.String())
+	}
+}
+
+// TestLoggerFormatterReachesHandler tests that the logger's formatter is used by handlers
+func TestLoggerFormatterReachesHandler(t *testing.T) {
+	var buf bytes.Buffer
+	handler := NewWriterHandler(&buf)
+
+	logger := NewLogger(WithHandler(handler), WithFormatter(NewJSONFormatter()))
+	logger.Info("json message")
+
+	var logEntry map[string]interface{}
+	if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &logEntry); err != nil {
+		t.Fatalf("Expected JSON output from logger formatter, got: %s", buf.String())
+	}
+
+	// A formatter set on the handler takes precedence
+	buf.Reset()
+	handler.SetFormatter(NewTextFormatter())
+	logger.Info("text message")
+
+	if json.Valid(bytes.TrimSpace(buf.Bytes())) {
+		t.Errorf("Expected handler formatter to override logger formatter, got: %s", buf.String())
+	}
+
+	// Wrapping handlers pass the logger's formatter on
+	buf.Reset()
+	msgOnly, _ := NewPatternFormatter("%msg")
+	NewLogger(WithHandler(NewJSONHandler(NewWriterHandler(&buf))), WithFormatter(msgOnly)).Info("wrapped")
+	if !json.Valid(bytes.TrimSpace(buf.Bytes())) || !strings.Contains(buf.String(), `"message":"wrapped"`) {
+		t.Errorf("Expected the wrapped handler to use the logger formatter, got: %s", buf.String())
+	}
+}
+
+// TestPipeline tests processor stages in front of a handler
+func TestPipeline(t *testing.T) {
+	var buf bytes.Buffer
+	handler := &testHandler{buf: &buf}
+
+	pipeline := NewPipeline(handler).
+		Use("enrich", EnrichProcessor(Fields{"service": "api"})).
+		Use("filter", LevelFilterProcessor(WarnLevel)).
+		Use("fail", ProcessorFunc(func(entry *Entry) (*Entry, error) {
+			return nil, errors.New("stage failed")
+		}))
+
+	logger := NewLogger()
+	logger.SetHandler(pipeline)
+
+	logger.Info("dropped by filter")
+	logger.Warn("kept despite error")
+
+	if strings.Contains(buf.String(), "dropped by filter") {
+		t.Error("Expected info entry to be dropped by the filter stage")
+	}
+	if !strings.Contains(buf.String(), "kept despite error") || !strings.Contains(buf.String(), "service=api") {
+		t.Errorf("Expected enriched warn entry, got: %s", buf.String())
+	}
+
+	stats := pipeline.Stats()
+	if stats[1].Dropped != 1 || stats[2].Errors != 1 {
+		t.Errorf("Unexpected stage stats: %+v", stats)
+	}
+
+	if !pipeline.Remove("filter") || len(pipeline.Stats()) != 2 {
+		t.Error("Expected filter stage to be removed")
+	}
+
+	pipeline.Use("strict", ProcessorFunc(func(entry *Entry) (*Entry, error) {
+		return nil, errors.New("strict failure")
+	}), WithStageErrorPolicy(StageErrorDrop))
+
+	if err := pipeline.Handle(&Entry{Level: InfoLevel, Message: "strict"}); err == nil {
+		t.Error("Expected error from stage with drop policy")
+	}
+}
+
+// TestTextFormatter tests text formatting
+func TestTextFormatter(t *testing.T) {
+	formatter := NewTextFormatter()
+
+	entry := &Entry{
+		Level:   InfoLevel,
+		Message: "test message",
+		Time:    time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC),
+		Fields: Fields{
+			"key": "value",
+		},
+	}
+
+	formatted, err := formatter.Format(entry)
+	if err != nil {
+		t.Fatalf("Failed to format entry: %v", err)
+	}
+
+	formattedStr := string(formatted)
+	if !strings.Contains(formattedStr, "test message") {
+		t.Errorf("Expected formatted string to contain 'test message', got: %s", formattedStr)
+	}
+
+	if !strings.Contains(formattedStr, "key=value") {
+		t.Errorf("Expected formatted string to contain 'key=value', got: %s", formattedStr)
+	}
+}
+
+// TestJSONFormatter tests JSON formatting
+func TestJSONFormatter(t *testing.T) {
+	formatter := NewJSONFormatter()
+
+	entry := &Entry{
+		Level:   InfoLevel,
+		Message: "test message",
+		Time:    time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC),
+		Fields: Fields{
+			"key": "value",
+		},
+	}
+
+	formatted, err := formatter.Format(entry)
+	if err != nil {
+		t.Fatalf("Failed to format entry: %v", err)
+	}
+
+	var logEntry map[string]interface{}
+	if err := json.Unmarshal(formatted, &logEntry); err != nil {
+		t.Fatalf("Failed to parse JSON: %v", err)
+	}
+
+	if logEntry["message"] != "test message" {
+		t.Errorf("Expected message to be 'test message', got: %v", logEntry["message"])
+	}
+
+	if logEntry["level"] != "info" {
+		t.Errorf("Expected level to be 'info', got: %v", logEntry["level"])
+	}
+
+	if logEntry["key"] != "value" {
+		t.Errorf("Expected key to be 'value', got: %v", logEntry["key"])
+	}
+}
+
+// TestDevFormatter tests inline fields, nested blocks and path shortening
+func TestDevFormatter(t *testing.T) {
+	formatter := NewDevFormatter(WithDevColors(false), WithDevModuleRoot("/src/shop"), WithDevMessageWidth(16))
+
+	type item struct {
+		SKU string
+		Qty int
+	}
+	entry := &Entry{
+		Level:   WarnLevel,
+		Message: "order placed",
+		Time:    time.Date(2024, 3, 1, 12, 4, 5, 123000000, time.UTC),
+		Caller:  "/src/shop/orders/api.go:42",
+		Fields: Fields{
+			"user_id": 42,
+			"note":    "two words",
+			"body":    "line one\nline two",
+			"request": map[string]interface{}{"method": "POST", "items": []item{{"A-1", 2}}},
+			"stacktrace": "goroutine 1 [running]:\nmain.handler(...)\n\t/src/shop/cmd/api/main.go:31 +0x1d\n" +
+				"net/http.HandlerFunc.ServeHTTP(...)\n\t/home/dev/go/pkg/mod/golang.org/x/net@v0.1.0/http.go:9 +0x2f\n",
+		},
+	}
+
+	formatted, err := formatter.Format(entry)
+	if err != nil {
+		t.Fatalf("Failed to format entry: %v", err)
+	}
+	want := strings.Join([]string{
+		`12:04:05.123 WARN  order placed     note="two words" user_id=42  (orders/api.go:42)`,
+		`    body: |`,
+		`        line one`,
+		`        line two`,
+		`    request:`,
+		`        items:`,
+		`            -`,
+		`                SKU: A-1`,
+		`                Qty: 2`,
+		`        method: POST`,
+		`    stacktrace:`,
+		`        goroutine 1 [running]:`,
+		`        main.handler(...)`,
+		`            cmd/api/main.go:31`,
+		`        net/http.HandlerFunc.ServeHTTP(...)`,
+		`            golang.org/x/net@v0.1.0/http.go:9`,
+	}, "\n")
+	if string(formatted) != want {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", formatted, want)
+	}
+
+	relative := NewDevFormatter(WithDevColors(false), WithDevTimeMode(DevTimeRelative))
+	formatted, _ = relative.Format(&Entry{Level: InfoLevel, Message: "hi", Time: time.Now().Add(1500 * time.Millisecond)})
+	if !strings.HasPrefix(string(formatted), "+1.5") || !strings.HasSuffix(string(formatted), "INFO  hi") {
+		t.Errorf("Unexpected relative output: %q", formatted)
+	}
+}
+
+// TestBinaryFormatters tests MessagePack and CBOR round trips through a handler
+func TestStacktrace(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(
+		WithHandler(NewWriterHandler(&buf)),
+		WithFormatter(NewJSONFormatter()),
+		WithStacktrace(true),
+	).WithFields(Fields{"user": "ada"})
+
+	logger.Error("failed")
+
+	var doc struct {
+		User       string  `json:"user"`
+		Stacktrace []Frame `json:"stacktrace"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+	}
+	if doc.User != "ada" || len(doc.Stacktrace) == 0 {
+		t.Fatalf("unexpected entry: %s", buf.String())
+	}
+	top := doc.Stacktrace[0]
+	if !strings.HasSuffix(top.Function, ".TestStacktrace") || !strings.HasSuffix(top.File, "logger_test.go") || top.Line == 0 {
+		t.Errorf("expected the test as the first frame, got %+v", top)
+	}
+	for _, fr := range doc.Stacktrace {
+		if strings.HasPrefix(fr.Function, "runtime.") || strings.HasPrefix(fr.Function, "testing.") {
+			t.Errorf("frame should have been filtered: %+v", fr)
+		}
+	}
+
+	// Skip and depth
+	helper := func(l Logger) { l.Error("from helper") }
+	var entries []*Entry
+	capture := WithHook(func(e *Entry) { entries = append(entries, e.Clone()) })
+	helper(NewLogger(WithHandler(nil), capture, WithStacktrace(true), WithStackDepth(1)))
+	helper(NewLogger(WithHandler(nil), capture, WithStacktrace(true), WithStackSkip(1), WithStackFilter(nil)))
+	if st := entries[0].Fields[StacktraceField].(StackTrace); len(st) != 1 || !strings.Contains(st[0].Function, "TestStacktrace.func") {
+		t.Errorf("expected only the helper frame, got %v", st)
+	}
+	if st := entries[1].Fields[StacktraceField].(StackTrace); !strings.HasSuffix(st[0].Function, ".TestStacktrace") || !strings.HasPrefix(st[len(st)-1].Function, "runtime.") {
+		t.Errorf("expected the unfiltered stack above the helper, got %v", st)
+	}
+
+	// Traced loggers keep stack and caller capture
+	entries = nil
+	traced := NewLogger(WithHandler(nil), capture, WithStacktrace(true), WithStackDepth(1), WithCaller(true)).
+		WithTrace(WithTraceContext(context.Background(), &TraceContext{TraceID: "t1"}))
+	traced.Error("traced")
+	if st, _ := entries[0].Fields[StacktraceField].(StackTrace); len(st) != 1 || entries[0].Caller == "" || entries[0].Fields["trace_id"] != "t1" {
+		t.Errorf("expected stack, caller and trace ID on traced entry, got %+v", entries[0])
+	}
+
+	// Text rendering
+	st := StackTrace{{Function: "main.run", File: "/src/app/main.go", Line: 12}, {Function: "main.main", File: "/src/app/main.go", Line: 5}}
+	out, _ := NewTextFormatter().Format(&Entry{Level: ErrorLevel, Message: "boom", Fields: Fields{StacktraceField: st, "id": 1}})
+	want := "[ERROR] boom {id=1}\n    main.run\n    \t/src/app/main.go:12\n    main.main\n    \t/src/app/main.go:5"
+	if !strings.HasSuffix(string(out), want) {
+		t.Errorf("text output = %q, want suffix %q", out, want)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(
+		WithHandler(NewWriterHandler(&buf)),
+		WithFormatter(NewJSONFormatter(WithJSONDisableTimestamp())),
+		WithLimits(Limits{MaxMessageLength: 20, MaxFieldLength: 10, MaxFields: 4, MaxDepth: 1, Marker: "~"}),
+	)
+	base := logger.WithFields(Fields{
+		"a": "héllo wörld!",
+		"b": Fields{"inner": Fields{"deep": 1}, "s": "0123456789abc"},
+		"c": 1,
+		"d": 2,
+	})
+
+	base.Info(strings.Repeat("x", 50))
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+	}
+	if msg := doc["message"]; msg != strings.Repeat("x", 19)+"~" {
+		t.Errorf("message = %q", msg)
+	}
+	if doc["a"] != "héllo w~" {
+		t.Errorf("a = %q", doc["a"])
+	}
+	if b := doc["b"].(map[string]interface{}); b["inner"] != "~" || b["s"] != "012345678~" {
+		t.Errorf("b = %v", b)
+	}
+	if _, ok := doc["d"]; ok || len(doc)-2 != 4 {
+		t.Errorf("expected d to be dropped to keep 4 fields including %s: %v", TruncatedField, doc)
+	}
+	if got := fmt.Sprint(doc[TruncatedField]); got != "[a b fields message]" {
+		t.Errorf("%s = %s", TruncatedField, got)
+	}
+
+	// The logger's own fields are untouched
+	buf.Reset()
+	base.WithFields(Fields{"c": nil}).Warn("short")
+	if !strings.Contains(buf.String(), `"c":null`) || strings.Contains(buf.String(), "héllo wörld!") {
+		t.Errorf("unexpected output: %s", buf.String())
+	}
+
+	// Limits shorter than the marker cut the marker; typed nil errors are kept
+	var nilErr *os.PathError
+	entry := &Entry{Message: "a long message", Fields: Fields{"err": error(nilErr)}}
+	Limits{MaxMessageLength: 5, MaxFieldLength: 3}.apply(entry)
+	if entry.Message != "...[t" || entry.Fields["err"] != error(nilErr) {
+		t.Errorf("unexpected limited entry: %q %v", entry.Message, entry.Fields)
+	}
+
+	// Other types are limited by their String or JSON form
+	long := strings.Repeat("y", 200)
+	entry = &Entry{Fields: Fields{
+		"list":   []string{long},
+		"map":    map[string]string{"k": long},
+		"struct": struct{ Name string }{long},
+		"url":    &url.URL{Scheme: "https", Host: "example.com", Path: "/" + long},
+		"short":  []string{"ok"},
+		"count":  12345678901234,
+	}}
+	Limits{MaxFieldLength: 20}.apply(entry)
+	for _, k := range []string{"list", "map", "struct", "url"} {
+		if s, ok := entry.Fields[k].(string); !ok || len(s) != 20 || !strings.HasSuffix(s, DefaultTruncationMarker) {
+			t.Errorf("expected %s to be cut to 20 bytes, got %#v", k, entry.Fields[k])
+		}
+	}
+	if _, ok := entry.Fields["short"].([]string); !ok || entry.Fields["count"] != 12345678901234 {
+		t.Errorf("expected short values to be kept, got %v", entry.Fields)
+	}
+
+	// Loggers derived with WithTrace keep the limits
+	buf.Reset()
+	traced := logger.WithTrace(WithTraceContext(context.Background(), &TraceContext{TraceID: "t1"}))
+	traced.Info(strings.Repeat("x", 50))
+	if !strings.Contains(buf.String(), `"trace_id":"t1"`) || strings.Contains(buf.String(), strings.Repeat("x", 21)) {
+		t.Errorf("expected the traced logger to limit the message: %s", buf.String())
+	}
+
+	// Total size: the largest field is shortened first, then the message
+	var huge bytes.Buffer
+	formatter := NewJSONFormatter(WithJSONDisableTimestamp())
+	handler := NewLimitHandler(NewWriterHandler(&huge, WithWriterFormatter(formatter)), Limits{MaxEntrySize: 200, Formatter: formatter})
+	NewLogger(WithHandler(handler)).WithFields(Fields{"body": strings.Repeat("b", 100000), "id": 7}).Info("response")
+	NewLogger(WithHandler(handler)).Info(strings.Repeat("m", 100000))
+	lines := strings.Split(strings.TrimSpace(huge.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", huge.String())
+	}
+	for _, line := range lines {
+		if len(line) > 200 || !strings.Contains(line, TruncatedField) {
+			t.Errorf("line not limited (%d bytes): %.300s", len(line), line)
+		}
+	}
+	if !strings.Contains(lines[0], `"id":7`) || !strings.Contains(lines[0], `"message":"response"`) {
+		t.Errorf("expected small fields and message to survive: %s", lines[0])
+	}
+}
+
+func TestBinaryFormatters(t *testing.T) {
+	when := time.Date(2024, 3, 1, 12, 0, 0, 123456000, time.UTC)
+	fields := Fields{
+		"user_id":  42,
+		"neg":      -300,
+		"big":      uint64(1 << 63),
+		"ratio":    0.25,
+		"ok":       true,
+		"none":     nil,
+		"err":      errors.New("boom"),
+		"elapsed":  1500 * time.Millisecond,
+		"tags":     []string{"a", "b"},
+		"payload":  []byte{0, 1, 2},
+		"nested":   Fields{"deep": map[string]interface{}{"x": 1.5}},
+		"started":  when,
+		"long_msg": strings.Repeat("x", 300),
+	}
+
+	decoders := map[string]struct {
+		formatter Formatter
+		decoder   func(io.Reader) func() (*Entry, error)
+	}{
+		"msgpack": {NewMsgpackFormatter(), func(r io.Reader) func() (*Entry, error) { return NewMsgpackDecoder(r).Decode }},
+		"cbor":    {NewCBORFormatter(), func(r io.Reader) func() (*Entry, error) { return NewCBORDecoder(r).Decode }},
+	}
+	for name, tc := range decoders {
+		var buf bytes.Buffer
+		logger := NewLogger(WithHandler(NewWriterHandler(&buf, WithWriterFormatter(tc.formatter))))
+		logger.WithFields(fields).Warn("first")
+		logger.Log(Level{"audit", 25}, "second")
+
+		decode := tc.decoder(&buf)
+		entry, err := decode()
+		if err != nil {
+			t.Fatalf("%s: failed to decode: %v", name, err)
+		}
+		if entry.Level != WarnLevel || entry.Message != "first" || time.Since(entry.Time) > time.Minute {
+			t.Errorf("%s: unexpected entry: %+v", name, entry)
+		}
+		f := entry.Fields
+		if f["user_id"] != int64(42) || f["neg"] != int64(-300) || f["big"] != uint64(1<<63) || f["ratio"] != 0.25 ||
+			f["ok"] != true || f["none"] != nil || f["err"] != "boom" || f["elapsed"] != int64(1500*time.Millisecond) ||
+			f["long_msg"] != strings.Repeat("x", 300) {
+			t.Errorf("%s: unexpected scalar fields: %v", name, f)
+		}
+		if tags, _ := f["tags"].([]interface{}); len(tags) != 2 || tags[1] != "b" {
+			t.Errorf("%s: unexpected tags: %v", name, f["tags"])
+		}
+		if payload, _ := f["payload"].([]byte); !bytes.Equal(payload, []byte{0, 1, 2}) {
+			t.Errorf("%s: unexpected payload: %v", name, f["payload"])
+		}
+		if nested, _ := f["nested"].(map[string]interface{}); nested["deep"].(map[string]interface{})["x"] != 1.5 {
+			t.Errorf("%s: unexpected nested: %v", name, f["nested"])
+		}
+		if started, _ := f["started"].(time.Time); !started.Equal(when) {
+			t.Errorf("%s: expected started %v, got %v", name, when, f["started"])
+		}
+
+		entry, err = decode()
+		if err != nil || entry.Level != (Level{"audit", 25}) || entry.Message != "second" || entry.Fields != nil {
+			t.Errorf("%s: unexpected second entry: %+v (%v)", name, entry, err)
+		}
+		if _, err := decode(); err != io.EOF {
+			t.Errorf("%s: expected io.EOF, got %v", name, err)
+		}
+	}
+
+	// Encodings from the MessagePack spec and RFC 8949 appendix A
+	for _, tc := range []struct {
+		got  []byte
+		want string
+	}{
+		{mustBinary(appendMsgpack(nil, int64(-33), 0)), "d0df"},
+		{mustBinary(appendMsgpack(nil, time.Unix(1, 0), 0)), "d6ff00000001"},
+		{mustBinary(appendCBOR(nil, int64(1000000), 0)), "1a000f4240"},
+		{mustBinary(appendCBOR(nil, int64(-1000), 0)), "3903e7"},
+		{mustBinary(appendCBOR(nil, time.Unix(1363896240, 0), 0)), "c11a514b67b0"},
+	} {
+		if got := hex.EncodeToString(tc.got); got != tc.want {
+			t.Errorf("Expected %s, got %s", tc.want, got)
+		}
+	}
+
+	if _, err := DecodeCBOREntry([]byte{0xbf}); err == nil {
+		t.Error("Expected error for indefinite-length map")
+	}
+	if _, err := DecodeMsgpackEntry([]byte{0x81, 0xa1}); err == nil {
+		t.Error("Expected error for truncated input")
+	}
+}
+
+func mustBinary(data []byte, err error) []byte {
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// TestJSONFormatterOptions tests key names, ordering, collisions and encoding
+func TestJSONFormatterOptions(t *testing.T) {
+	entry := &Entry{
+		Level:   WarnLevel,
+		Message: "<b>slow</b>",
+		Time:    time.Date(2024, 3, 1, 12, 0, 0, 123000000, time.UTC),
+		Caller:  "app.go:7",
+		Fields: Fields{
+			"zeta":    1.5,
+			"alpha":   []string{"a", "b"},
+			"request": "r-1",
+			"level":   "user level",
+			"err":     errors.New("boom"),
+		},
+	}
+
+	formatter := NewJSONFormatter(
+		WithJSONKeys("ts", "", "msg", ""),
+		WithJSONTimestampFormat(JSONTimeEpochMillis),
+		WithJSONFieldOrder([]string{"request"}),
+		WithJSONEscapeHTML(false),
+	)
+	formatted, err := formatter.Format(entry)
+	if err != nil {
+		t.Fatalf("Failed to format entry: %v", err)
+	}
+	want := `{"ts":1709294400123,"level":"warn","msg":"<b>slow</b>","caller":"app.go:7","request":"r-1","alpha":["a","b"],"err":"boom","fields.level":"user level","zeta":1.5}`
+	if string(formatted) != want {
+		t.Errorf("Unexpected output:\n got %s\nwant %s", formatted, want)
+	}
+
+	// Defaults keep RFC3339 timestamps; a typed nil error is written as null
+	var nilErr *os.PathError
+	formatted, err = NewJSONFormatter().Format(&Entry{Level: InfoLevel, Time: entry.Time, Fields: Fields{"err": error(nilErr)}})
+	if err != nil || !strings.HasPrefix(string(formatted), `{"time":"2024-03-01T12:00:00Z",`) || !strings.Contains(string(formatted), `"err":null`) {
+		t.Errorf("Unexpected default output: %s (%v)", formatted, err)
+	}
+
+	formatted, _ = NewJSONFormatter(WithJSONCollisionPolicy(JSONCollisionNest), WithJSONDisableTimestamp()).Format(entry)
+	var doc map[string]interface{}
+	if err := json.Unmarshal(formatted, &doc); err != nil {
+		t.Fatalf("Expected valid JSON: %v", err)
+	}
+	nested, _ := doc["fields"].(map[string]interface{})
+	if doc["level"] != "warn" || nested["level"] != "user level" || doc["time"] != nil {
+		t.Errorf("Expected clashing field nested under fields: %s", formatted)
+	}
+	if !strings.Contains(string(formatted), `\u003cb\u003e`) {
+		t.Errorf("Expected HTML escaping by default: %s", formatted)
+	}
+
+	formatted, _ = NewJSONFormatter(WithJSONCollisionPolicy(JSONCollisionOverwrite)).Format(entry)
+	if !strings.Contains(string(formatted), `"level":"user level"`) || strings.Contains(string(formatted), `"warn"`) {
+		t.Errorf("Expected field to overwrite level: %s", formatted)
+	}
+
+	// The encoder matches encoding/json for common values
+	values := []interface{}{
+		"quote\" back\\ ctl\x01 tab\t \u2028 bad\xff", 0.000001, 1e21, 1e-7, float32(3.14), -42, uint64(1 << 63),
+		true, nil, time.Duration(1500), map[string]interface{}{"b": 1, "a": []interface{}{"x", 2.5}},
+	}
+	for _, v := range values {
+		got, err := appendJSONValue(nil, v, true)
+		if err != nil {
+			t.Fatalf("Failed to encode %v: %v", v, err)
+		}
+		expected, _ := json.Marshal(v)
+		if string(got) != string(expected) {
+			t.Errorf("Encoding mismatch for %#v:\n got %s\nwant %s", v, got, expected)
+		}
+	}
+}
+
+// TestECSFormatter tests the mapping of entries to ECS fields
+func TestECSFormatter(t *testing.T) {
+	formatter := NewECSFormatter(
+		WithECSNamespace("app"),
+		WithECSContainerInfo(&ContainerInfo{ID: "abc123", Image: "api", ImageTag: "1.4", Hostname: "node-1"}),
+	)
+
+	ctx := WithTraceContext(context.Background(), &TraceContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7"})
+	entry := &Entry{
+		Level:   ErrorLevel,
+		Message: "payment failed",
+		Time:    time.Date(2024, 1, 2, 15, 4, 5, 123000000, time.FixedZone("CET", 3600)),
+		Caller:  "/src/app/payments.go:42",
+		Context: ctx,
+		Fields:  Fields{"error": errors.New("card declined"), "order_id": 7},
+	}
+
+	formatted, err := formatter.Format(entry)
+	if err != nil {
+		t.Fatalf("Failed to format entry: %v", err)
+	}
+
+	var doc struct {
+		Timestamp string `json:"@timestamp"`
+		Log       struct {
+			Level  string `json:"level"`
+			Origin struct {
+				File struct {
+					Name string `json:"name"`
+					Line int    `json:"line"`
+				} `json:"file"`
+			} `json:"origin"`
+		} `json:"log"`
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+		Trace     struct{ ID string } `json:"trace"`
+		Span      struct{ ID string } `json:"span"`
+		Container struct {
+			ID    string `json:"id"`
+			Image struct {
+				Tag []string `json:"tag"`
+			} `json:"image"`
+		} `json:"container"`
+		Host struct{ Hostname string } `json:"host"`
+		ECS  struct{ Version string }  `json:"ecs"`
+		App  map[string]interface{}    `json:"app"`
+	}
+	if err := json.Unmarshal(formatted, &doc); err != nil {
+		t.Fatalf("Expected valid JSON: %v", err)
+	}
+
+	if doc.Timestamp != "2024-01-02T14:04:05.123Z" || doc.Log.Level != "error" || doc.ECS.Version != ECSVersion {
+		t.Errorf("Unexpected base fields: %s", formatted)
+	}
+	if doc.Log.Origin.File.Name != "payments.go" || doc.Log.Origin.File.Line != 42 {
+		t.Errorf("Unexpected log.origin: %+v", doc.Log.Origin)
+	}
+	if doc.Error.Message != "card declined" || doc.Trace.ID != "4bf92f3577b34da6a3ce929d0e0e4736" || doc.Span.ID != "00f067aa0ba902b7" {
+		t.Errorf("Unexpected error or trace fields: %s", formatted)
+	}
+	if doc.Container.ID != "abc123" || len(doc.Container.Image.Tag) != 1 || doc.Host.Hostname != "node-1" {
+		t.Errorf("Unexpected container fields: %s", formatted)
+	}
+	if doc.App["order_id"] != float64(7) || doc.App["error"] != nil {
+		t.Errorf("Expected remaining fields under app, got %v", doc.App)
+	}
+}
+
+// TestPatternFormatter tests compiled log4j-style layouts
+func TestPatternFormatter(t *testing.T) {
+	formatter, err := NewPatternFormatter("%d{2006-01-02T15:04:05.000Z07:00} %-5level [%logger] %msg %fields{json} (%caller{short})%n")
+	if err != nil {
+		t.Fatalf("Failed to compile pattern: %v", err)
+	}
+
+	entry := &Entry{
+		Level:   InfoLevel,
+		Message: "order placed",
+		Time:    time.Date(2024, 3, 1, 12, 0, 0, 5000000, time.UTC),
+		Caller:  "/src/app/orders.go:42",
+		Fields:  Fields{"logger": "orders", "order_id": 7, "error": errors.New("none")},
+	}
+	formatted, err := formatter.Format(entry)
+	if err != nil {
+		t.Fatalf("Failed to format entry: %v", err)
+	}
+	want := `2024-03-01T12:00:00.005Z INFO  [orders] order placed {"error":"none","order_id":7} (orders.go:42)`
+	if string(formatted) != want {
+		t.Errorf("Unexpected output:\n got %q\nwant %q", formatted, want)
+	}
+
+	formatter, err = NewPatternFormatter("%5p|%.-5msg|%.3X{logger}|%L|%fields|%level{lower}%%")
+	if err != nil {
+		t.Fatalf("Failed to compile pattern: %v", err)
+	}
+	formatted, _ = formatter.Format(entry)
+	if want := ` INFO|order|ers|42|error=none order_id=7|info%`; string(formatted) != want {
+		t.Errorf("Unexpected output:\n got %q\nwant %q", formatted, want)
+	}
+
+	// The default layout with the default logger options has no caller
+	var buf bytes.Buffer
+	formatter, _ = NewPatternFormatter("")
+	NewLogger(WithHandler(NewWriterHandler(&buf)), WithFormatter(formatter)).Info("hello")
+	if line := strings.TrimSuffix(buf.String(), "\n"); !strings.HasSuffix(line, "INFO  hello") {
+		t.Errorf("Expected no caller or trailing space, got %q", line)
+	}
+
+	for _, layout := range []string{"%bogus", "%X", "%d{unclosed", "%fields{xml}", "%-"} {
+		if _, err := NewPatternFormatter(layout); err == nil {
+			t.Errorf("Expected error for layout %q", layout)
+		}
+	}
+}
+
+// TestCEFAndLEEFFormatters tests header escaping, severity and extensions
+func TestCEFAndLEEFFormatters(t *testing.T) {
+	entry := &Entry{
+		Level:   WarnLevel,
+		Message: "login failed | user=admin",
+		Time:    time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		Fields: Fields{
+			"remote_ip": "10.0.0.7",
+			"user":      "admin",
+			"order_id":  "a=b\\c",
+			"event_id":  "auth-401",
+			"attempt":   3,
+		},
+	}
+
+	cef, err := NewCEFFormatter(WithCEFProduct("Acme", "Shop|Web", "2.1")).Format(entry)
+	if err != nil {
+		t.Fatalf("Failed to format CEF: %v", err)
+	}
+	want := `CEF:0|Acme|Shop\|Web|2.1|auth-401|login failed \| user=admin|5|rt=1709294400000 attempt=3 orderId=a\=b\\c src=10.0.0.7 suser=admin`
+	if string(cef) != want {
+		t.Errorf("Unexpected CEF:\n got %s\nwant %s", cef, want)
+	}
+
+	delete(entry.Fields, "event_id")
+	entry.Fields["note"] = "line1\nline2\tend"
+	leef, err := NewLEEFFormatter(WithLEEFProduct("Acme", "Shop", "2.1"), WithLEEFEventID("app")).Format(entry)
+	if err != nil {
+		t.Fatalf("Failed to format LEEF: %v", err)
+	}
+	header := "LEEF:2.0|Acme|Shop|2.1|app|x09|"
+	if !strings.HasPrefix(string(leef), header) {
+		t.Fatalf("Unexpected LEEF header: %s", leef)
+	}
+	attrs := strings.Split(strings.TrimPrefix(string(leef), header), "\t")
+	expected := []string{
+		"devTime=Mar 01 2024 12:00:00.000 UTC",
+		"devTimeFormat=MMM dd yyyy HH:mm:ss.SSS z",
+		"sev=5",
+		"msg=login failed | user=admin",
+		"attempt=3",
+		"note=line1 line2 end",
+		"orderId=a=b\\c",
+		"src=10.0.0.7",
+		"usrName=admin",
+	}
+	if strings.Join(attrs, ",") != strings.Join(expected, ",") {
+		t.Errorf("Unexpected LEEF attributes:\n got %q\nwant %q", attrs, expected)
+	}
+
+	for level, severity := range map[Level]int{DebugLevel: 1, InfoLevel: 3, ErrorLevel: 7, FatalLevel: 9, PanicLevel: 10, {"trace", 5}: 0} {
+		if got := CEFSeverity(level); got != severity {
+			t.Errorf("Expected CEF severity %d for %s, got %d", severity, level, got)
+		}
+	}
+}
+
+// TestEMFFormatter tests metric directives built from Logger.Metric
+func TestEMFFormatter(t *testing.T) {
+	var buf bytes.Buffer
+	handler := NewEMFHandler(&buf,
+		WithEMFNamespace("checkout"),
+		WithEMFDimensions([]string{"service"}, []string{"service", "region"}),
+	)
+	logger := NewLogger(WithHandler(handler)).WithFields(Fields{"service": "payments", "attempt": 3})
+
+	measured := logger.Metric("latency", 12.5, "milliseconds")
+	measured = measured.Metric("latency", 14, "Milliseconds")
+	measured.Metric("orders", 1, "furlongs").Info("order placed")
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("Expected valid JSON: %v (%s)", err, buf.String())
+	}
+
+	if doc["message"] != "order placed" || doc["service"] != "payments" || doc["orders"] != float64(1) || doc[MetricsField] != nil {
+		t.Errorf("Unexpected fields: %s", buf.String())
+	}
+	if latency, _ := doc["latency"].([]interface{}); len(latency) != 2 || latency[0] != 12.5 || latency[1] != float64(14) {
+		t.Errorf("Expected latency values array, got %v", doc["latency"])
+	}
+
+	aws, _ := doc["_aws"].(map[string]interface{})
+	if _, ok := aws["Timestamp"].(float64); !ok {
+		t.Errorf("Expected _aws.Timestamp, got %v", aws)
+	}
+	directives, _ := aws["CloudWatchMetrics"].([]interface{})
+	if len(directives) != 1 {
+		t.Fatalf("Expected one directive, got %v", aws)
+	}
+	directive := directives[0].(map[string]interface{})
+	if directive["Namespace"] != "checkout" {
+		t.Errorf("Unexpected namespace: %v", directive["Namespace"])
+	}
+	if dims, _ := directive["Dimensions"].([]interface{}); len(dims) != 1 {
+		t.Errorf("Expected only the complete dimension set, got %v", directive["Dimensions"])
+	}
+	metrics, _ := directive["Metrics"].([]interface{})
+	if len(metrics) != 2 {
+		t.Fatalf("Expected two metric definitions, got %v", metrics)
+	}
+	latency := metrics[0].(map[string]interface{})
+	orders := metrics[1].(map[string]interface{})
+	if latency["Name"] != "latency" || latency["Unit"] != "Milliseconds" || orders["Unit"] != "None" {
+		t.Errorf("Unexpected metric definitions: %v", metrics)
+	}
+
+	buf.Reset()
+	logger.Info("no metrics")
+	if strings.Contains(buf.String(), "_aws") {
+		t.Errorf("Expected plain JSON without metrics, got %s", buf.String())
+	}
+
+	// Other formatters leave the raw metrics out
+	for _, formatter := range []Formatter{NewJSONFormatter(), NewTextFormatter(), NewLogfmtFormatter()} {
+		buf.Reset()
+		NewLogger(WithHandler(NewWriterHandler(&buf)), WithFormatter(formatter)).Metric("latency", 1, "Seconds").Info("measured")
+		if strings.Contains(buf.String(), MetricsField) || !strings.Contains(buf.String(), "measured") {
+			t.Errorf("%T: expected metrics to be stripped, got %s", formatter, buf.String())
+		}
+	}
+
+	// So do the handlers that encode fields themselves
+	entry := &Entry{Level: ErrorLevel, Message: "measured", Fields: Fields{"id": 1, MetricsField: []Metric{{Name: "latency", Value: 1}}}}
+	for _, kv := range newOTLPLogRecord(entry).attributes {
+		if kv.key == MetricsField {
+			t.Errorf("Expected OTLP attributes without metrics, got %v", kv)
+		}
+	}
+	if fields := NewWebhookMessage([]*Entry{entry}, 0).Entries[0].Fields; len(fields) != 1 || fields[0].Key != "id" {
+		t.Errorf("Expected webhook fields without metrics, got %v", fields)
+	}
+	recorder := &recordingSQLDriver{}
+	db := sql.OpenDB(recorder)
+	defer db.Close()
+	sqlHandler, err := NewSQLHandler(SQLConfig{DB: db, Dialect: SQLDialectPostgres, SkipSchema: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlHandler.Handle(entry)
+	sqlHandler.Close()
+	if execs := recorder.statements(); len(execs) != 1 || execs[0].args[5].Value != `{"id":1}` {
+		t.Errorf("Expected the SQL fields column without metrics, got %v", execs)
+	}
+
+	// Metrics recorded on a span logger keep logging to the span
+	tracer := NewOTelTracer("checkout")
+	_, span := tracer.StartSpan(context.Background(), "pay")
+	NewSpanLogger(NewLogger(WithHandler(nil)), span, tracer).Metric("latency", 1, "Seconds").Info("measured")
+	if len(span.Logs) != 1 {
+		t.Errorf("Expected the entry on the span, got %d span logs", len(span.Logs))
+	}
+}
+
+// TestDatadogFormatter tests reserved attributes and decimal trace IDs
+func TestDatadogFormatter(t *testing.T) {
+	t.Setenv("DD_SERVICE", "env-service")
+	t.Setenv("DD_ENV", "staging")
+	formatter := NewDatadogFormatter(WithDatadogHost("web-1"))
+
+	ctx := WithTraceContext(context.Background(), &TraceContext{
+		TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanID:  "00f067aa0ba902b7",
+	})
+	entry := &Entry{
+		Level:   ErrorLevel,
+		Message: "charge failed",
+		Time:    time.Now(),
+		Caller:  "/src/app/pay.go:12",
+		Context: ctx,
+		Fields: Fields{
+			"service":    "checkout",
+			"version":    "1.4.2",
+			"error":      errors.New("card declined"),
+			"stacktrace": "goroutine 1 [running]",
+			"status":     402,
+			"order_id":   "o-1",
+		},
+	}
+
+	formatted, err := formatter.Format(entry)
+	if err != nil {
+		t.Fatalf("Failed to format entry: %v", err)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(formatted, &doc); err != nil {
+		t.Fatalf("Expected valid JSON: %v", err)
+	}
+
+	if doc["status"] != "error" || doc["message"] != "charge failed" || doc["host"] != "web-1" || doc["service"] != "checkout" {
+		t.Errorf("Unexpected base fields: %s", formatted)
+	}
+	if doc["order_id"] != "o-1" || doc["fields.status"] != float64(402) {
+		t.Errorf("Unexpected custom fields: %s", formatted)
+	}
+
+	dd, _ := doc["dd"].(map[string]interface{})
+	if dd["trace_id"] != "11803532876627986230" || dd["span_id"] != "67667974448284343" {
+		t.Errorf("Unexpected trace IDs: %v", dd)
+	}
+	if dd["service"] != "checkout" || dd["env"] != "staging" || dd["version"] != "1.4.2" {
+		t.Errorf("Unexpected dd tags: %v", dd)
+	}
+
+	errorFields, _ := doc["error"].(map[string]interface{})
+	if errorFields["message"] != "card declined" || errorFields["kind"] != "*errors.errorString" || errorFields["stack"] != "goroutine 1 [running]" {
+		t.Errorf("Unexpected error fields: %v", errorFields)
+	}
+
+	if got := DatadogID("not-hex"); got != "" {
+		t.Errorf("Expected empty ID for invalid hex, got %s", got)
+	}
+}
+
+// TestGCPFormatter tests the Cloud Logging structured layout
+func TestGCPFormatter(t *testing.T) {
+	formatter := NewGCPFormatter(
+		WithGCPProjectID("shop-prod"),
+		WithGCPLabels(map[string]string{"team": "payments"}),
+		WithGCPLabelFields("tenant"),
+		WithGCPHTTPRequestFields(DefaultGCPHTTPRequestFields),
+	)
+
+	tracer := NewOTelTracer("checkout")
+	ctx, span := tracer.StartSpan(context.Background(), "pay")
+	entry := &Entry{
+		Level:   WarnLevel,
+		Message: "slow request",
+		Time:    time.Now(),
+		Caller:  "/src/app/handler.go:88",
+		Context: ctx,
+		Fields: Fields{
+			"method":      "POST",
+			"path":        "/pay",
+			"status":      502,
+			"duration_ms": 1250,
+			"tenant":      "acme",
+			"attempt":     2,
+		},
+	}
+
+	formatted, err := formatter.Format(entry)
+	if err != nil {
+		t.Fatalf("Failed to format entry: %v", err)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(formatted, &doc); err != nil {
+		t.Fatalf("Expected valid JSON: %v", err)
+	}
+
+	if doc["severity"] != "WARNING" || doc["message"] != "slow request" || doc["attempt"] != float64(2) {
+		t.Errorf("Unexpected base fields: %s", formatted)
+	}
+	if doc["logging.googleapis.com/trace"] != "projects/shop-prod/traces/"+span.TraceID || doc["logging.googleapis.com/spanId"] != span.SpanID {
+		t.Errorf("Unexpected trace fields: %s", formatted)
+	}
+
+	location, _ := doc["logging.googleapis.com/sourceLocation"].(map[string]interface{})
+	if location["file"] != "/src/app/handler.go" || location["line"] != "88" {
+		t.Errorf("Unexpected sourceLocation: %v", location)
+	}
+
+	request, _ := doc["httpRequest"].(map[string]interface{})
+	if request["requestMethod"] != "POST" || request["requestUrl"] != "/pay" || request["status"] != float64(502) || request["latency"] != "1.25s" {
+		t.Errorf("Unexpected httpRequest: %v", request)
+	}
+
+	labels, _ := doc["logging.googleapis.com/labels"].(map[string]interface{})
+	if labels["team"] != "payments" || labels["tenant"] != "acme" || doc["tenant"] != nil {
+		t.Errorf("Unexpected labels: %v", labels)
+	}
+
+	// Values of the wrong type stay in the payload; unsigned latencies work
+	entry.Fields = Fields{"status": "paid", "latency": uint32(2)}
+	formatted, _ = formatter.Format(entry)
+	doc = nil
+	json.Unmarshal(formatted, &doc)
+	request, _ = doc["httpRequest"].(map[string]interface{})
+	if doc["status"] != "paid" || request["status"] != nil || request["latency"] != "2s" {
+		t.Errorf("Unexpected httpRequest handling: %s", formatted)
+	}
+
+	// Without a mapping, request fields are ordinary fields
+	formatted, _ = NewGCPFormatter().Format(&Entry{Level: InfoLevel, Fields: Fields{"status": 502, "method": "GET"}})
+	doc = nil
+	json.Unmarshal(formatted, &doc)
+	if doc["httpRequest"] != nil || doc["status"] != float64(502) || doc["method"] != "GET" {
+		t.Errorf("Expected no httpRequest by default: %s", formatted)
+	}
+
+	for level, severity := range map[Level]string{DebugLevel: "DEBUG", InfoLevel: "INFO", {"notice", 25}: "NOTICE", FatalLevel: "CRITICAL", PanicLevel: "ALERT"} {
+		if got := GCPSeverity(level); got != severity {
+			t.Errorf("Expected %s for %s, got %s", severity, level, got)
+		}
+	}
+}
+
+// TestLogfmtFormatter tests quoting, ordering and flattening
+func TestLogfmtFormatter(t *testing.T) {
+	formatter := NewLogfmtFormatter(WithLogfmtFieldOrder([]string{"request_id"}))
+
+	entry := &Entry{
+		Level:   WarnLevel,
+		Message: `said "hi"`,
+		Time:    time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC),
+		Fields: Fields{
+			"zeta":       "a=b",
+			"request_id": "r1",
+			"user":       map[string]interface{}{"id": 42, "name": "Ada Lovelace"},
+			"msg":        "shadowed",
+			"note":       "line1\nline2",
+			"empty":      "",
+		},
+	}
+
+	formatted, err := formatter.Format(entry)
+	if err != nil {
+		t.Fatalf("Failed to format entry: %v", err)
+	}
+
+	expected := `ts=2024-01-02T15:04:05Z level=warn msg="said \"hi\"" request_id=r1 empty="" fields.msg=shadowed note="line1\nline2" user.id=42 user.name="Ada Lovelace" zeta="a=b"`
+	if string(formatted) != expected {
+		t.Errorf("Unexpected logfmt output:\n got: %s\nwant: %s", formatted, expected)
+	}
+
+	// Nil pointer errors and Stringers are written as null
+	var nilErr *os.PathError
+	var nilURL *url.URL
+	formatted, err = formatter.Format(&Entry{Level: InfoLevel, Time: entry.Time, Fields: Fields{"error": error(nilErr), "cause": error(nilErr), "url": nilURL}})
+	if err != nil || !strings.HasSuffix(string(formatted), "cause=null error=null url=null") {
+		t.Errorf("Unexpected nil pointer output: %s (%v)", formatted, err)
+	}
+}
+
+// handlerFunc adapts a function to the Handler interface
+type handlerFunc func(*Entry) error
+
+func (f handlerFunc) Handle(entry *Entry) error { return f(entry) }
+
+// testHandler is a test handler that writes to a buffer
+type testHandler struct {
+	buf       *bytes.Buffer
+	formatter Formatter
+}
+
+func (h *testHandler) Handle(entry *Entry) error {
+	formatter := h.formatter
+	if formatter == nil {
+		formatter = NewTextFormatter()
+	}
+
+	formatted, err := formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+
+	_, err = h.buf.Write(formatted)
+	return err
+}
+
+// setFormatter sets the formatter for the test handler
+func (h *testHandler) setFormatter(formatter Formatter) {
+	h.formatter = formatter
+}
+
+// BenchmarkLogger benchmarks the logger performance
+func BenchmarkLogger(b *testing.B) {
+	logger := NewLogger()
+	logger.SetLevel(InfoLevel)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Info("benchmark message")
+	}
+}
+
+// BenchmarkFastLogger benchmarks the fast logger performance
+func BenchmarkFastLogger(b *testing.B) {
+	logger := NewLogger()
+	logger.SetLevel(InfoLevel)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.InfoFast("benchmark fast message")
+	}
+}
+
+// BenchmarkLoggerWithFields benchmarks logging with fields
+func BenchmarkLoggerWithFields(b *testing.B) {
+	logger := NewLogger()
+	logger.SetLevel(InfoLevel)
+
+	fields := Fields{
+		"user_id": 123,
+		"action":  "benchmark",
+		"count":   b.N,
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.WithFields(fields).Info("benchmark message with fields")
+	}
+}
+
+// BenchmarkJSONFormatter benchmarks JSON encoding of an entry with fields
+func BenchmarkJSONFormatter(b *testing.B) {
+	formatter := NewJSONFormatter()
+	entry := &Entry{
+		Level:   InfoLevel,
+		Message: "benchmark message",
+		Time:    time.Now(),
+		Caller:  "app.go:7",
+		Fields:  Fields{"user_id": 123, "action": "benchmark", "latency": 1.25, "ok": true},
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := formatter.Format(entry); err != nil {
+			b.Fatal(err)
+		}
 	}
 }
 
@@ -0,0 +1,201 @@
+// Package logtest provides helpers for testing code that logs with the logging package.
+//
+// A Recorder stores copies of every entry it handles so assertions can be
+// made after the fact, even though the logger reuses pooled entries:
+//
+//	logger, rec := logtest.NewLogger()
+//	doWork(logger)
+//	rec.AssertLogged(t, logging.ErrorLevel, "failed", logging.Fields{"attempt": 3})
+package logtest
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jakubbbdev/go-logging/pkg/logging"
+)
+
+// Recorder is a handler that keeps an in-memory copy of every entry it handles
+type Recorder struct {
+	entries []logging.Entry
+	mu      sync.RWMutex
+}
+
+// NewRecorder creates a new, empty recorder
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// NewLogger creates a debug-level logger that records to a new recorder
+func NewLogger(opts ...logging.Option) (logging.Logger, *Recorder) {
+	rec := NewRecorder()
+	opts = append([]logging.Option{logging.WithLevel(logging.DebugLevel)}, opts...)
+	opts = append(opts, logging.WithHandler(rec))
+	return logging.NewLogger(opts...), rec
+}
+
+// Handle implements the logging.Handler interface by storing a copy of the entry
+func (r *Recorder) Handle(entry *logging.Entry) error {
+	copied := entry.Clone()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, *copied)
+	return nil
+}
+
+// Entries returns a copy of all recorded entries in the order they were handled
+func (r *Recorder) Entries() []logging.Entry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entries := make([]logging.Entry, len(r.entries))
+	copy(entries, r.entries)
+	return entries
+}
+
+// Len returns the number of recorded entries
+func (r *Recorder) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.entries)
+}
+
+// Filter returns the recorded entries for which match returns true
+func (r *Recorder) Filter(match func(entry logging.Entry) bool) []logging.Entry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var matched []logging.Entry
+	for _, entry := range r.entries {
+		if match(entry) {
+			matched = append(matched, entry)
+		}
+	}
+	return matched
+}
+
+// FilterLevel returns the recorded entries at the given level
+func (r *Recorder) FilterLevel(level logging.Level) []logging.Entry {
+	return r.Filter(func(entry logging.Entry) bool {
+		return entry.Level == level
+	})
+}
+
+// Last returns the most recently recorded entry
+func (r *Recorder) Last() (logging.Entry, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.entries) == 0 {
+		return logging.Entry{}, false
+	}
+	return r.entries[len(r.entries)-1], true
+}
+
+// Reset discards all recorded entries
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = nil
+}
+
+// AssertLogged fails the test unless an entry was recorded at level whose
+// message contains msg and whose fields include every key in fields with an
+// equal value. A nil fields map matches any entry.
+func (r *Recorder) AssertLogged(t testing.TB, level logging.Level, msg string, fields logging.Fields) {
+	t.Helper()
+
+	if len(r.Filter(matcher(level, msg, fields))) == 0 {
+		t.Errorf("expected %s entry containing %q with fields %v; recorded:\n%s",
+			level, msg, fields, r.dump())
+	}
+}
+
+// AssertNotLogged fails the test if an entry matching level, msg and fields was recorded
+func (r *Recorder) AssertNotLogged(t testing.TB, level logging.Level, msg string, fields logging.Fields) {
+	t.Helper()
+
+	if matched := r.Filter(matcher(level, msg, fields)); len(matched) > 0 {
+		t.Errorf("expected no %s entry containing %q with fields %v; found %d",
+			level, msg, fields, len(matched))
+	}
+}
+
+// AssertLen fails the test unless exactly n entries were recorded
+func (r *Recorder) AssertLen(t testing.TB, n int) {
+	t.Helper()
+
+	if got := r.Len(); got != n {
+		t.Errorf("expected %d recorded entries, got %d:\n%s", n, got, r.dump())
+	}
+}
+
+// matcher returns a predicate matching entries by level, message substring and fields
+func matcher(level logging.Level, msg string, fields logging.Fields) func(logging.Entry) bool {
+	return func(entry logging.Entry) bool {
+		if entry.Level != level || !strings.Contains(entry.Message, msg) {
+			return false
+		}
+		for k, want := range fields {
+			got, ok := entry.Fields[k]
+			if !ok || !reflect.DeepEqual(got, want) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// dump renders the recorded entries for failure messages
+func (r *Recorder) dump() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.entries) == 0 {
+		return "  (none)"
+	}
+
+	var b strings.Builder
+	for _, entry := range r.entries {
+		fmt.Fprintf(&b, "  [%s] %s %v\n", entry.Level, entry.Message, entry.Fields)
+	}
+	return b.String()
+}
+
+// TBHandler is a handler that writes formatted entries through testing.TB.Log,
+// so log output is attributed to the test and only shown on failure or with -v
+type TBHandler struct {
+	tb        testing.TB
+	formatter logging.Formatter
+}
+
+// NewTBHandler creates a handler logging through tb
+func NewTBHandler(tb testing.TB) *TBHandler {
+	return &TBHandler{
+		tb: tb,
+		formatter: logging.NewTextFormatter(func(f *logging.TextFormatter) {
+			f.UseColors = false
+		}),
+	}
+}
+
+// Handle implements the logging.Handler interface for test output
+func (h *TBHandler) Handle(entry *logging.Entry) error {
+	formatted, err := h.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+
+	h.tb.Helper()
+	h.tb.Log(string(formatted))
+	return nil
+}
+
+// SetFormatter sets the formatter for the test handler
+func (h *TBHandler) SetFormatter(formatter logging.Formatter) {
+	h.formatter = formatter
+}
@@ -0,0 +1,40 @@
+package logtest
+
+import (
+	"testing"
+
+	"github.com/jakubbbdev/go-logging/pkg/logging"
+)
+
+// TestRecorder tests recording and asserting on log entries
+func TestRecorder(t *testing.T) {
+	logger, rec := NewLogger()
+
+	logger.WithFields(logging.Fields{"attempt": 3}).Error("request failed")
+	logger.Info("request done")
+	logger.Debug("details")
+
+	rec.AssertLen(t, 3)
+	rec.AssertLogged(t, logging.ErrorLevel, "failed", logging.Fields{"attempt": 3})
+	rec.AssertLogged(t, logging.InfoLevel, "done", nil)
+	rec.AssertNotLogged(t, logging.WarnLevel, "", nil)
+
+	if n := len(rec.FilterLevel(logging.DebugLevel)); n != 1 {
+		t.Errorf("Expected 1 debug entry, got %d", n)
+	}
+
+	// Recorded entries must survive reuse of pooled entries
+	entries := rec.Entries()
+	if entries[0].Message != "request failed" || entries[0].Fields["attempt"] != 3 {
+		t.Errorf("Recorded entry was modified: %+v", entries[0])
+	}
+
+	rec.Reset()
+	rec.AssertLen(t, 0)
+}
+
+// TestTBHandler tests routing log output through testing.TB
+func TestTBHandler(t *testing.T) {
+	logger := logging.NewLogger(logging.WithHandler(NewTBHandler(t)))
+	logger.Info("visible with go test -v")
+}
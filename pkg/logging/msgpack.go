@@ -0,0 +1,369 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// msgpackTimestampExt is the MessagePack timestamp extension type
+const msgpackTimestampExt = -1
+
+// MsgpackFormatter formats log entries as MessagePack maps using the binary
+// schema described in binary.go. Timestamps use the standard timestamp
+// extension type -1.
+type MsgpackFormatter struct{}
+
+// NewMsgpackFormatter creates a new MessagePack formatter.
+func NewMsgpackFormatter() Formatter {
+	return &MsgpackFormatter{}
+}
+
+// Format implements the Formatter interface for MessagePack output
+func (f *MsgpackFormatter) Format(entry *Entry) ([]byte, error) {
+	entry = stripMetrics(entry)
+	return appendMsgpack(make([]byte, 0, 128), binaryEntryMap(entry), 0)
+}
+
+// appendMsgpack appends the MessagePack encoding of a normalized value
+func appendMsgpack(buf []byte, v interface{}, depth int) ([]byte, error) {
+	if depth > binaryMaxDepth {
+		return buf, errBinaryTooDeep
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return append(buf, 0xc0), nil
+	case bool:
+		if v {
+			return append(buf, 0xc3), nil
+		}
+		return append(buf, 0xc2), nil
+	case int64:
+		if v >= 0 {
+			return appendMsgpackUint(buf, uint64(v)), nil
+		}
+		switch {
+		case v >= -32:
+			return append(buf, byte(int8(v))), nil
+		case v >= math.MinInt8:
+			return append(buf, 0xd0, byte(int8(v))), nil
+		case v >= math.MinInt16:
+			return appendUintBE(append(buf, 0xd1), uint64(v), 2), nil
+		case v >= math.MinInt32:
+			return appendUintBE(append(buf, 0xd2), uint64(v), 4), nil
+		default:
+			return appendUintBE(append(buf, 0xd3), uint64(v), 8), nil
+		}
+	case uint64:
+		return appendMsgpackUint(buf, v), nil
+	case float32:
+		return appendUintBE(append(buf, 0xca), uint64(math.Float32bits(v)), 4), nil
+	case float64:
+		return appendUintBE(append(buf, 0xcb), math.Float64bits(v), 8), nil
+	case string:
+		n := uint64(len(v))
+		switch {
+		case n < 32:
+			buf = append(buf, 0xa0|byte(n))
+		case n <= math.MaxUint8:
+			buf = append(buf, 0xd9, byte(n))
+		case n <= math.MaxUint16:
+			buf = appendUintBE(append(buf, 0xda), n, 2)
+		default:
+			buf = appendUintBE(append(buf, 0xdb), n, 4)
+		}
+		return append(buf, v...), nil
+	case []byte:
+		n := uint64(len(v))
+		switch {
+		case n <= math.MaxUint8:
+			buf = append(buf, 0xc4, byte(n))
+		case n <= math.MaxUint16:
+			buf = appendUintBE(append(buf, 0xc5), n, 2)
+		default:
+			buf = appendUintBE(append(buf, 0xc6), n, 4)
+		}
+		return append(buf, v...), nil
+	case time.Time:
+		return appendMsgpackTime(buf, v), nil
+	case []interface{}:
+		buf = appendMsgpackLength(buf, uint64(len(v)), 0x90, 0xdc, 0xdd)
+		var err error
+		for _, item := range v {
+			if buf, err = appendMsgpack(buf, item, depth+1); err != nil {
+				return buf, err
+			}
+		}
+		return buf, nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		buf = appendMsgpackLength(buf, uint64(len(v)), 0x80, 0xde, 0xdf)
+		var err error
+		for _, k := range keys {
+			if buf, err = appendMsgpack(buf, k, depth+1); err != nil {
+				return buf, err
+			}
+			if buf, err = appendMsgpack(buf, v[k], depth+1); err != nil {
+				return buf, err
+			}
+		}
+		return buf, nil
+	default:
+		return appendMsgpack(buf, binaryValue(v), depth+1)
+	}
+}
+
+// appendMsgpackUint appends n in the smallest unsigned integer format
+func appendMsgpackUint(buf []byte, n uint64) []byte {
+	switch {
+	case n < 128:
+		return append(buf, byte(n))
+	case n <= math.MaxUint8:
+		return append(buf, 0xcc, byte(n))
+	case n <= math.MaxUint16:
+		return appendUintBE(append(buf, 0xcd), n, 2)
+	case n <= math.MaxUint32:
+		return appendUintBE(append(buf, 0xce), n, 4)
+	default:
+		return appendUintBE(append(buf, 0xcf), n, 8)
+	}
+}
+
+// appendMsgpackLength appends an array or map header
+func appendMsgpackLength(buf []byte, n uint64, fix, len16, len32 byte) []byte {
+	switch {
+	case n < 16:
+		return append(buf, fix|byte(n))
+	case n <= math.MaxUint16:
+		return appendUintBE(append(buf, len16), n, 2)
+	default:
+		return appendUintBE(append(buf, len32), n, 4)
+	}
+}
+
+// appendMsgpackTime appends t as a timestamp 32, 64 or 96 extension
+func appendMsgpackTime(buf []byte, t time.Time) []byte {
+	sec, nsec := t.Unix(), uint64(t.Nanosecond())
+	if uint64(sec)>>34 == 0 {
+		if nsec == 0 && sec <= math.MaxUint32 {
+			buf = append(buf, 0xd6, byte(0xff))
+			return appendUintBE(buf, uint64(sec), 4)
+		}
+		buf = append(buf, 0xd7, byte(0xff))
+		return appendUintBE(buf, nsec<<34|uint64(sec), 8)
+	}
+	buf = append(buf, 0xc7, 12, byte(0xff))
+	buf = appendUintBE(buf, nsec, 4)
+	return appendUintBE(buf, uint64(sec), 8)
+}
+
+// MsgpackDecoder reads entries written by MsgpackFormatter from a stream.
+type MsgpackDecoder struct {
+	r binaryReader
+}
+
+// NewMsgpackDecoder creates a decoder reading from r. Newlines written
+// between entries by line-oriented handlers are skipped.
+func NewMsgpackDecoder(r io.Reader) *MsgpackDecoder {
+	return &MsgpackDecoder{r: newBinaryReader(r)}
+}
+
+// DecodeMsgpackEntry decodes a single entry written by MsgpackFormatter
+func DecodeMsgpackEntry(data []byte) (*Entry, error) {
+	return NewMsgpackDecoder(bytes.NewReader(data)).Decode()
+}
+
+// Decode reads the next entry, returning io.EOF at the end of the stream
+func (d *MsgpackDecoder) Decode() (*Entry, error) {
+	if err := d.r.skipSeparators(); err != nil {
+		return nil, err
+	}
+	v, err := d.decodeValue(0)
+	if err != nil {
+		return nil, fmt.Errorf("msgpack: %w", err)
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("msgpack: expected map, got %T", v)
+	}
+	return entryFromBinaryMap(m)
+}
+
+// decodeValue reads one value
+func (d *MsgpackDecoder) decodeValue(depth int) (interface{}, error) {
+	if depth > binaryMaxDepth {
+		return nil, errBinaryTooDeep
+	}
+	b, err := d.r.readByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case b <= 0x7f:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b&0xf0 == 0x80:
+		return d.decodeMap(uint64(b&0x0f), depth)
+	case b&0xf0 == 0x90:
+		return d.decodeArray(uint64(b&0x0f), depth)
+	case b&0xe0 == 0xa0:
+		return d.decodeString(uint64(b & 0x1f))
+	}
+
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.r.readUint(1 << (b - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		return d.r.readN(n)
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.r.readUint(1 << (b - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeExt(n)
+	case 0xca:
+		n, err := d.r.readUint(4)
+		return float64(math.Float32frombits(uint32(n))), err
+	case 0xcb:
+		n, err := d.r.readUint(8)
+		return math.Float64frombits(n), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := d.r.readUint(1 << (b - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		if n <= math.MaxInt64 {
+			return int64(n), nil
+		}
+		return n, nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (b - 0xd0)
+		n, err := d.r.readUint(size)
+		if err != nil {
+			return nil, err
+		}
+		shift := uint(64 - 8*size)
+		return int64(n<<shift) >> shift, nil
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.decodeExt(1 << (b - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.r.readUint(1 << (b - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeString(n)
+	case 0xdc, 0xdd:
+		n, err := d.r.readUint(2 << (b - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeArray(n, depth)
+	case 0xde, 0xdf:
+		n, err := d.r.readUint(2 << (b - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeMap(n, depth)
+	}
+	return nil, fmt.Errorf("invalid type byte 0x%02x", b)
+}
+
+func (d *MsgpackDecoder) decodeString(n uint64) (interface{}, error) {
+	data, err := d.r.readN(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (d *MsgpackDecoder) decodeArray(n uint64, depth int) (interface{}, error) {
+	if n > binaryMaxLength {
+		return nil, fmt.Errorf("array length %d exceeds limit", n)
+	}
+	items := make([]interface{}, 0, binaryCapacity(n))
+	for i := uint64(0); i < n; i++ {
+		item, err := d.decodeValue(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (d *MsgpackDecoder) decodeMap(n uint64, depth int) (interface{}, error) {
+	if n > binaryMaxLength {
+		return nil, fmt.Errorf("map length %d exceeds limit", n)
+	}
+	m := make(map[string]interface{}, binaryCapacity(n))
+	for i := uint64(0); i < n; i++ {
+		key, err := d.decodeValue(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		value, err := d.decodeValue(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := key.(string); ok {
+			m[s] = value
+		} else {
+			m[fmt.Sprint(key)] = value
+		}
+	}
+	return m, nil
+}
+
+// decodeExt reads an extension type and n bytes of data; only timestamps
+// are supported
+func (d *MsgpackDecoder) decodeExt(n uint64) (interface{}, error) {
+	typ, err := d.r.readByte()
+	if err != nil {
+		return nil, err
+	}
+	data, err := d.r.readN(n)
+	if err != nil {
+		return nil, err
+	}
+	if int8(typ) != msgpackTimestampExt {
+		return nil, fmt.Errorf("unsupported extension type %d", int8(typ))
+	}
+
+	var n64 uint64
+	for _, b := range data {
+		n64 = n64<<8 | uint64(b)
+	}
+	switch len(data) {
+	case 4:
+		return time.Unix(int64(n64), 0), nil
+	case 8:
+		return time.Unix(int64(n64&(1<<34-1)), int64(n64>>34)), nil
+	case 12:
+		var sec uint64
+		for _, b := range data[4:] {
+			sec = sec<<8 | uint64(b)
+		}
+		nsec := uint64(data[0])<<24 | uint64(data[1])<<16 | uint64(data[2])<<8 | uint64(data[3])
+		return time.Unix(int64(sec), int64(nsec)), nil
+	}
+	return nil, fmt.Errorf("invalid timestamp length %d", len(data))
+}
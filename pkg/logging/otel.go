@@ -172,44 +172,56 @@ func SpanFromContext(ctx context.Context) *OTelSpan {
 
 // OTelHandler wraps another handler and adds OpenTelemetry integration
 type OTelHandler struct {
-	handler Handler
-	tracer  *OTelTracer
+	handler   Handler
+	processor Processor
+	tracer    *OTelTracer
 }
 
 // NewOTelHandler creates a new OpenTelemetry-integrated handler
 func NewOTelHandler(handler Handler, tracer *OTelTracer) Handler {
 	return &OTelHandler{
-		handler: handler,
-		tracer:  tracer,
+		handler:   handler,
+		processor: OTelProcessor(tracer),
+		tracer:    tracer,
 	}
 }
 
 // Handle implements the Handler interface with OTel integration
 func (h *OTelHandler) Handle(entry *Entry) error {
-	// Add span information to entry if available
-	if entry.Context != nil {
-		if span := SpanFromContext(entry.Context); span != nil {
-			if entry.Fields == nil {
-				entry.Fields = make(Fields)
-			}
-			entry.Fields["otel.trace_id"] = span.TraceID
-			entry.Fields["otel.span_id"] = span.SpanID
-			if span.ParentID != "" {
-				entry.Fields["otel.parent_id"] = span.ParentID
-			}
-			entry.Fields["otel.operation"] = span.Operation
+	return processAndHandle(h.processor, h.handler, entry)
+}
 
-			// Log to span
-			h.tracer.LogToSpan(span, entry.Level, entry.Message, entry.Fields)
+// OTelProcessor adds span information from the entry context and records
+// the entry on the span
+func OTelProcessor(tracer *OTelTracer) Processor {
+	return ProcessorFunc(func(entry *Entry) (*Entry, error) {
+		if entry.Context == nil {
+			return entry, nil
+		}
+		span := SpanFromContext(entry.Context)
+		if span == nil {
+			return entry, nil
+		}
 
-			// Set error on span if it's an error log
-			if entry.Level == ErrorLevel || entry.Level == FatalLevel || entry.Level == PanicLevel {
-				h.tracer.SetSpanError(span, fmt.Errorf("%s", entry.Message))
-			}
+		fields := cloneFields(entry.Fields)
+		fields["otel.trace_id"] = span.TraceID
+		fields["otel.span_id"] = span.SpanID
+		if span.ParentID != "" {
+			fields["otel.parent_id"] = span.ParentID
 		}
-	}
+		fields["otel.operation"] = span.Operation
+		entry.Fields = fields
+
+		// Log to span
+		tracer.LogToSpan(span, entry.Level, entry.Message, entry.Fields)
 
-	return h.handler.Handle(entry)
+		// Set error on span if it's an error log
+		if entry.Level == ErrorLevel || entry.Level == FatalLevel || entry.Level == PanicLevel {
+			tracer.SetSpanError(span, fmt.Errorf("%s", entry.Message))
+		}
+
+		return entry, nil
+	})
 }
 
 // OTelHookFactory creates hooks for OpenTelemetry integration
@@ -323,3 +335,6 @@ func (sl *SpanLogger) SetLevel(level Level)                   { sl.logger.SetLev
 func (sl *SpanLogger) SetHandler(handler Handler)             { sl.logger.SetHandler(handler) }
 func (sl *SpanLogger) SetFormatter(formatter Formatter)       { sl.logger.SetFormatter(formatter) }
 func (sl *SpanLogger) AddHook(hook Hook)                      { sl.logger.AddHook(hook) }
+func (sl *SpanLogger) Metric(name string, value float64, unit string) Logger {
+	return &SpanLogger{logger: sl.logger.Metric(name, value, unit), span: sl.span, tracer: sl.tracer}
+}
@@ -0,0 +1,669 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OTLP protocols supported by OTLPHandler
+const (
+	OTLPProtocolProtobuf = "http/protobuf"
+	OTLPProtocolJSON     = "http/json"
+)
+
+// otlpScopeName is the instrumentation scope reported with every record
+const otlpScopeName = "github.com/jakubbbdev/go-logging"
+
+// OTLPConfig represents OTLP logs exporter configuration
+type OTLPConfig struct {
+	// OTel provides service.name and service.version resource attributes
+	OTel OTelConfig
+
+	// Endpoint is the OTLP/HTTP logs URL (default http://localhost:4318/v1/logs)
+	Endpoint string
+
+	// Protocol is OTLPProtocolProtobuf (default) or OTLPProtocolJSON
+	Protocol string
+
+	Headers map[string]string
+
+	// ResourceAttributes are added to the resource of every export
+	ResourceAttributes map[string]interface{}
+
+	// IncludeContainerInfo adds container.*, host.* and k8s.* resource
+	// attributes from DetectContainerEnvironment
+	IncludeContainerInfo bool
+
+	// BatchSize is the number of records sent per export (default 512)
+	BatchSize int
+
+	// FlushInterval is the maximum time a record waits before export (default 5s)
+	FlushInterval time.Duration
+
+	// MaxQueueSize caps buffered records; the oldest are dropped (default 4 * BatchSize)
+	MaxQueueSize int
+
+	// OnError is called when an export fails
+	OnError func(err error)
+
+	Client *http.Client
+}
+
+// OTLPHandler exports entries as OpenTelemetry LogRecords over OTLP/HTTP
+type OTLPHandler struct {
+	config   OTLPConfig
+	resource []otlpKeyValue
+	queue    []*Entry
+	dropped  int64
+	mu       sync.Mutex
+	sendMu   sync.Mutex
+	flush    chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+// NewOTLPHandler creates a new OTLP logs exporter and starts its batch loop
+func NewOTLPHandler(config OTLPConfig) *OTLPHandler {
+	if config.Endpoint == "" {
+		config.Endpoint = "http://localhost:4318/v1/logs"
+	}
+	if config.Protocol == "" {
+		config.Protocol = OTLPProtocolProtobuf
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 512
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = 5 * time.Second
+	}
+	if config.MaxQueueSize <= 0 {
+		config.MaxQueueSize = 4 * config.BatchSize
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	h := &OTLPHandler{
+		config:   config,
+		resource: otlpResource(config),
+		flush:    make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go h.run()
+	return h
+}
+
+// Handle implements the Handler interface by queueing the entry for export
+func (h *OTLPHandler) Handle(entry *Entry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.queue = append(h.queue, entry.Clone())
+	h.trimQueue()
+
+	if len(h.queue) >= h.config.BatchSize {
+		select {
+		case h.flush <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Flush exports all queued records. A batch that fails with a retryable
+// error, such as a network error or a 5xx status, is put back at the front
+// of the queue for the next flush; other failures drop the batch and count
+// it in Dropped.
+func (h *OTLPHandler) Flush() error {
+	h.sendMu.Lock()
+	defer h.sendMu.Unlock()
+
+	for {
+		h.mu.Lock()
+		n := len(h.queue)
+		if n > h.config.BatchSize {
+			n = h.config.BatchSize
+		}
+		batch := h.queue[:n:n]
+		h.queue = h.queue[n:]
+		h.mu.Unlock()
+
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := h.export(batch); err != nil {
+			h.mu.Lock()
+			if IsRetryable(err) {
+				h.queue = append(batch, h.queue...)
+				h.trimQueue()
+			} else {
+				h.dropped += int64(len(batch))
+			}
+			h.mu.Unlock()
+			return err
+		}
+	}
+}
+
+// trimQueue drops the oldest records beyond MaxQueueSize. It must be
+// called with h.mu held.
+func (h *OTLPHandler) trimQueue() {
+	if overflow := len(h.queue) - h.config.MaxQueueSize; overflow > 0 {
+		h.queue = h.queue[overflow:]
+		h.dropped += int64(overflow)
+	}
+}
+
+// Dropped returns the number of records dropped because the queue was full
+// or their export failed with an error that is not retryable
+func (h *OTLPHandler) Dropped() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.dropped
+}
+
+// Close stops the batch loop and exports any queued records
+func (h *OTLPHandler) Close() error {
+	h.once.Do(func() {
+		close(h.stop)
+		<-h.done
+	})
+	return h.Flush()
+}
+
+// run exports batches when full or every FlushInterval
+func (h *OTLPHandler) run() {
+	defer close(h.done)
+
+	ticker := time.NewTicker(h.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-h.flush:
+		case <-h.stop:
+			return
+		}
+		if err := h.Flush(); err != nil && h.config.OnError != nil {
+			h.config.OnError(err)
+		}
+	}
+}
+
+// export sends one batch to the collector
+func (h *OTLPHandler) export(batch []*Entry) error {
+	records := make([]otlpLogRecord, 0, len(batch))
+	for _, entry := range batch {
+		records = append(records, newOTLPLogRecord(entry))
+	}
+
+	var body []byte
+	var contentType string
+	var err error
+	if h.config.Protocol == OTLPProtocolJSON {
+		contentType = "application/json"
+		body, err = encodeOTLPJSON(h.resource, records)
+	} else {
+		contentType = "application/x-protobuf"
+		body = encodeOTLPProtobuf(h.resource, records)
+	}
+	if err != nil {
+		return Permanent(err)
+	}
+
+	req, err := http.NewRequest("POST", h.config.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return Permanent(err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "go-logging/1.0")
+	for k, v := range h.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := h.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("OTLP export failed: %w", &HTTPStatusError{StatusCode: resp.StatusCode})
+	}
+	return nil
+}
+
+// OTelSeverity maps a level to an OpenTelemetry SeverityNumber
+func OTelSeverity(level Level) int {
+	switch {
+	case level.Value >= PanicLevel.Value:
+		return 24 // FATAL4
+	case level.Value >= FatalLevel.Value:
+		return 21 // FATAL
+	case level.Value >= ErrorLevel.Value:
+		return 17 // ERROR
+	case level.Value >= WarnLevel.Value:
+		return 13 // WARN
+	case level.Value >= InfoLevel.Value:
+		return 9 // INFO
+	case level.Value >= DebugLevel.Value:
+		return 5 // DEBUG
+	default:
+		return 1 // TRACE
+	}
+}
+
+// otlpKeyValue is an attribute with a Go value to be encoded as AnyValue
+type otlpKeyValue struct {
+	key   string
+	value interface{}
+}
+
+// otlpLogRecord is the exporter's view of a LogRecord
+type otlpLogRecord struct {
+	timeUnixNano     uint64
+	observedUnixNano uint64
+	severityNumber   int
+	severityText     string
+	body             string
+	attributes       []otlpKeyValue
+	traceID          []byte
+	spanID           []byte
+}
+
+// newOTLPLogRecord converts an entry to a log record
+func newOTLPLogRecord(entry *Entry) otlpLogRecord {
+	entry = stripMetrics(entry)
+	record := otlpLogRecord{
+		timeUnixNano:     uint64(entry.Time.UnixNano()),
+		observedUnixNano: uint64(time.Now().UnixNano()),
+		severityNumber:   OTelSeverity(entry.Level),
+		severityText:     strings.ToUpper(entry.Level.String()),
+		body:             entry.Message,
+		attributes:       sortedKeyValues(entry.Fields),
+	}
+
+	if file, line := splitCaller(entry.Caller); file != "" {
+		record.attributes = append(record.attributes, otlpKeyValue{"code.filepath", file})
+		if line > 0 {
+			record.attributes = append(record.attributes, otlpKeyValue{"code.lineno", line})
+		}
+	}
+
+	traceID, spanID := entryTraceIDs(entry)
+	record.traceID = decodeHexID(traceID, 16)
+	record.spanID = decodeHexID(spanID, 8)
+	return record
+}
+
+// entryTraceIDs returns the hex trace and span IDs for an entry from its
+// OTelSpan, TraceContext or trace fields, in that order
+func entryTraceIDs(entry *Entry) (string, string) {
+	if entry.Context != nil {
+		if span := SpanFromContext(entry.Context); span != nil {
+			return span.TraceID, span.SpanID
+		}
+		if tc := TraceFromContext(entry.Context); tc != nil {
+			return tc.TraceID, tc.SpanID
+		}
+	}
+	for _, prefix := range []string{"otel.", ""} {
+		traceID, _ := entry.Fields[prefix+"trace_id"].(string)
+		spanID, _ := entry.Fields[prefix+"span_id"].(string)
+		if traceID != "" {
+			return traceID, spanID
+		}
+	}
+	return "", ""
+}
+
+// decodeHexID decodes a hex ID of the given byte length, or returns nil
+func decodeHexID(id string, size int) []byte {
+	if len(id) != size*2 {
+		return nil
+	}
+	b, err := hex.DecodeString(id)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// otlpResource builds the resource attributes for config
+func otlpResource(config OTLPConfig) []otlpKeyValue {
+	attrs := make(map[string]interface{})
+
+	if config.IncludeContainerInfo {
+		if info := DetectContainerEnvironment(); info != nil {
+			setIfNotEmpty(attrs, "host.name", info.Hostname)
+			setIfNotEmpty(attrs, "container.id", info.ID)
+			setIfNotEmpty(attrs, "container.name", info.Name)
+			setIfNotEmpty(attrs, "container.image.name", info.Image)
+			setIfNotEmpty(attrs, "container.image.tag", info.ImageTag)
+			setIfNotEmpty(attrs, "k8s.pod.name", info.PodName)
+			setIfNotEmpty(attrs, "k8s.namespace.name", info.PodNamespace)
+			if info.Environment != "unknown" {
+				setIfNotEmpty(attrs, "deployment.environment", info.Environment)
+			}
+		}
+	}
+
+	for k, v := range config.ResourceAttributes {
+		attrs[k] = v
+	}
+
+	serviceName := config.OTel.ServiceName
+	if serviceName == "" {
+		serviceName = "unknown_service"
+	}
+	attrs["service.name"] = serviceName
+	setIfNotEmpty(attrs, "service.version", config.OTel.ServiceVersion)
+
+	return sortedKeyValues(attrs)
+}
+
+// setIfNotEmpty sets attrs[key] if value is not empty
+func setIfNotEmpty(attrs map[string]interface{}, key, value string) {
+	if value != "" {
+		attrs[key] = value
+	}
+}
+
+// sortedKeyValues converts a map to key-values in key order
+func sortedKeyValues(m map[string]interface{}) []otlpKeyValue {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	kvs := make([]otlpKeyValue, 0, len(keys))
+	for _, k := range keys {
+		kvs = append(kvs, otlpKeyValue{k, m[k]})
+	}
+	return kvs
+}
+
+// normalizeOTLPValue reduces a Go value to string, bool, int64, float64,
+// []byte, []interface{} or map[string]interface{}
+func normalizeOTLPValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string, bool, int64, float64, []byte, []interface{}:
+		return val
+	case int:
+		return int64(val)
+	case int8:
+		return int64(val)
+	case int16:
+		return int64(val)
+	case int32:
+		return int64(val)
+	case uint:
+		return int64(val)
+	case uint8:
+		return int64(val)
+	case uint16:
+		return int64(val)
+	case uint32:
+		return int64(val)
+	case uint64:
+		return int64(val)
+	case float32:
+		return float64(val)
+	case Fields:
+		return map[string]interface{}(val)
+	case map[string]interface{}:
+		return val
+	case []string:
+		values := make([]interface{}, len(val))
+		for i, s := range val {
+			values[i] = s
+		}
+		return values
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	case time.Duration:
+		return val.String()
+	case error, fmt.Stringer:
+		return fieldString(val)
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// encodeOTLPJSON encodes an ExportLogsServiceRequest in OTLP/JSON
+func encodeOTLPJSON(resource []otlpKeyValue, records []otlpLogRecord) ([]byte, error) {
+	logRecords := make([]map[string]interface{}, 0, len(records))
+	for _, r := range records {
+		record := map[string]interface{}{
+			"timeUnixNano":         strconv.FormatUint(r.timeUnixNano, 10),
+			"observedTimeUnixNano": strconv.FormatUint(r.observedUnixNano, 10),
+			"severityNumber":       r.severityNumber,
+			"severityText":         r.severityText,
+			"body":                 otlpAnyValueJSON(r.body),
+			"attributes":           otlpKeyValuesJSON(r.attributes),
+		}
+		if r.traceID != nil {
+			record["traceId"] = hex.EncodeToString(r.traceID)
+		}
+		if r.spanID != nil {
+			record["spanId"] = hex.EncodeToString(r.spanID)
+		}
+		logRecords = append(logRecords, record)
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"resourceLogs": []map[string]interface{}{
+			{
+				"resource": map[string]interface{}{
+					"attributes": otlpKeyValuesJSON(resource),
+				},
+				"scopeLogs": []map[string]interface{}{
+					{
+						"scope":      map[string]string{"name": otlpScopeName},
+						"logRecords": logRecords,
+					},
+				},
+			},
+		},
+	})
+}
+
+// otlpKeyValuesJSON encodes attributes as OTLP/JSON KeyValues
+func otlpKeyValuesJSON(kvs []otlpKeyValue) []map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(kvs))
+	for _, kv := range kvs {
+		out = append(out, map[string]interface{}{
+			"key":   kv.key,
+			"value": otlpAnyValueJSON(kv.value),
+		})
+	}
+	return out
+}
+
+// otlpAnyValueJSON encodes a value as an OTLP/JSON AnyValue
+func otlpAnyValueJSON(v interface{}) map[string]interface{} {
+	switch val := normalizeOTLPValue(v).(type) {
+	case bool:
+		return map[string]interface{}{"boolValue": val}
+	case int64:
+		return map[string]interface{}{"intValue": strconv.FormatInt(val, 10)}
+	case float64:
+		return map[string]interface{}{"doubleValue": val}
+	case []byte:
+		return map[string]interface{}{"bytesValue": val}
+	case []interface{}:
+		values := make([]map[string]interface{}, 0, len(val))
+		for _, item := range val {
+			values = append(values, otlpAnyValueJSON(item))
+		}
+		return map[string]interface{}{"arrayValue": map[string]interface{}{"values": values}}
+	case map[string]interface{}:
+		return map[string]interface{}{"kvlistValue": map[string]interface{}{"values": otlpKeyValuesJSON(sortedKeyValues(val))}}
+	default:
+		return map[string]interface{}{"stringValue": val}
+	}
+}
+
+// encodeOTLPProtobuf encodes an ExportLogsServiceRequest in protobuf wire format
+func encodeOTLPProtobuf(resource []otlpKeyValue, records []otlpLogRecord) []byte {
+	// Resource
+	var res []byte
+	for _, kv := range resource {
+		res = protoAppendMessage(res, 1, protoKeyValue(kv))
+	}
+
+	// ScopeLogs
+	var scope []byte
+	scope = protoAppendString(scope, 1, otlpScopeName)
+
+	var scopeLogs []byte
+	scopeLogs = protoAppendMessage(scopeLogs, 1, scope)
+	for _, r := range records {
+		scopeLogs = protoAppendMessage(scopeLogs, 2, protoLogRecord(r))
+	}
+
+	// ResourceLogs
+	var resourceLogs []byte
+	resourceLogs = protoAppendMessage(resourceLogs, 1, res)
+	resourceLogs = protoAppendMessage(resourceLogs, 2, scopeLogs)
+
+	// ExportLogsServiceRequest
+	return protoAppendMessage(nil, 1, resourceLogs)
+}
+
+// protoLogRecord encodes a LogRecord message
+func protoLogRecord(r otlpLogRecord) []byte {
+	var b []byte
+	b = protoAppendFixed64(b, 1, r.timeUnixNano)
+	b = protoAppendVarintField(b, 2, uint64(r.severityNumber))
+	b = protoAppendString(b, 3, r.severityText)
+	b = protoAppendMessage(b, 5, protoAnyValue(r.body))
+	for _, kv := range r.attributes {
+		b = protoAppendMessage(b, 6, protoKeyValue(kv))
+	}
+	if r.traceID != nil {
+		b = protoAppendBytes(b, 9, r.traceID)
+	}
+	if r.spanID != nil {
+		b = protoAppendBytes(b, 10, r.spanID)
+	}
+	b = protoAppendFixed64(b, 11, r.observedUnixNano)
+	return b
+}
+
+// protoKeyValue encodes a KeyValue message
+func protoKeyValue(kv otlpKeyValue) []byte {
+	var b []byte
+	b = protoAppendString(b, 1, kv.key)
+	b = protoAppendMessage(b, 2, protoAnyValue(kv.value))
+	return b
+}
+
+// protoAnyValue encodes an AnyValue message; oneof members are always written
+func protoAnyValue(v interface{}) []byte {
+	var b []byte
+	switch val := normalizeOTLPValue(v).(type) {
+	case bool:
+		var n uint64
+		if val {
+			n = 1
+		}
+		b = protoAppendTag(b, 2, 0)
+		b = protoAppendVarint(b, n)
+	case int64:
+		b = protoAppendTag(b, 3, 0)
+		b = protoAppendVarint(b, uint64(val))
+	case float64:
+		b = protoAppendTag(b, 4, 1)
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(val))
+	case []interface{}:
+		var arr []byte
+		for _, item := range val {
+			arr = protoAppendMessage(arr, 1, protoAnyValue(item))
+		}
+		b = protoAppendMessage(b, 5, arr)
+	case map[string]interface{}:
+		var list []byte
+		for _, kv := range sortedKeyValues(val) {
+			list = protoAppendMessage(list, 1, protoKeyValue(kv))
+		}
+		b = protoAppendMessage(b, 6, list)
+	case []byte:
+		b = protoAppendMessage(b, 7, val)
+	case string:
+		b = protoAppendMessage(b, 1, []byte(val))
+	}
+	return b
+}
+
+// protoAppendTag appends a field tag
+func protoAppendTag(b []byte, field int, wireType int) []byte {
+	return protoAppendVarint(b, uint64(field<<3|wireType))
+}
+
+// protoAppendVarint appends a base-128 varint
+func protoAppendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+// protoAppendVarintField appends a non-zero varint field
+func protoAppendVarintField(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protoAppendTag(b, field, 0)
+	return protoAppendVarint(b, v)
+}
+
+// protoAppendFixed64 appends a non-zero fixed64 field
+func protoAppendFixed64(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protoAppendTag(b, field, 1)
+	return binary.LittleEndian.AppendUint64(b, v)
+}
+
+// protoAppendString appends a non-empty string field
+func protoAppendString(b []byte, field int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	return protoAppendMessage(b, field, []byte(s))
+}
+
+// protoAppendBytes appends a non-empty bytes field
+func protoAppendBytes(b []byte, field int, p []byte) []byte {
+	if len(p) == 0 {
+		return b
+	}
+	return protoAppendMessage(b, field, p)
+}
+
+// protoAppendMessage appends a length-delimited field, even if empty
+func protoAppendMessage(b []byte, field int, msg []byte) []byte {
+	b = protoAppendTag(b, field, 2)
+	b = protoAppendVarint(b, uint64(len(msg)))
+	return append(b, msg...)
+}
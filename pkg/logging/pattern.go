@@ -0,0 +1,390 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultPatternLayout is a log4j-style layout similar to TextFormatter
+const DefaultPatternLayout = "%d{2006-01-02T15:04:05.000Z07:00} %-5level %msg %fields %caller{short}"
+
+// patternWriter appends one part of a compiled layout to buf
+type patternWriter func(buf *bytes.Buffer, entry *Entry)
+
+// PatternFormatter formats log entries with a log4j-style layout such as
+//
+//	%d{2006-01-02T15:04:05.000Z07:00} %-5level [%logger] %msg %fields{json} (%caller{short})%n
+//
+// The layout is compiled once into a list of writers. Conversions:
+//
+//	%d, %date{layout}     time; Go layout or ISO8601, RFC3339, UNIX, UNIX_MILLIS
+//	%p, %level{lower}     level, upper-case unless {lower}
+//	%m, %msg, %message    message
+//	%c, %logger           the "logger" field
+//	%X{key}, %field{key}  a single field
+//	%fields{json}         remaining fields as key=value pairs or a JSON object
+//	%caller{short}        caller as file:line, {short} drops the directory
+//	%F, %L                caller file and line
+//	%ex, %stacktrace      the stacktrace field
+//	%traceid, %spanid     trace and span IDs from the entry's context
+//	%n, %%                line break and percent sign
+//
+// A conversion may be padded and truncated like log4j: %-5level pads to five
+// characters on the right, %5level on the left, %.10logger keeps the last ten
+// characters and %.-10msg the first ten. Fields shown by %logger, %X or %ex
+// are left out of %fields. A trailing %n is dropped since handlers already
+// end each entry with a line break, and trailing spaces left by conversions
+// with nothing to write, such as %caller without caller capture, are
+// trimmed.
+type PatternFormatter struct {
+	Layout string
+
+	writers  []patternWriter
+	consumed map[string]bool
+}
+
+// patternConverters builds the writer for a conversion name and its option
+var patternConverters map[string]func(f *PatternFormatter, option string) (patternWriter, error)
+
+func init() {
+	date := func(f *PatternFormatter, option string) (patternWriter, error) {
+		return patternDate(option), nil
+	}
+	level := func(f *PatternFormatter, option string) (patternWriter, error) {
+		lower := option == "lower"
+		return func(buf *bytes.Buffer, entry *Entry) {
+			if lower {
+				buf.WriteString(entry.Level.Name)
+			} else {
+				buf.WriteString(strings.ToUpper(entry.Level.Name))
+			}
+		}, nil
+	}
+	message := func(f *PatternFormatter, option string) (patternWriter, error) {
+		return func(buf *bytes.Buffer, entry *Entry) {
+			buf.WriteString(entry.Message)
+		}, nil
+	}
+	field := func(f *PatternFormatter, option string) (patternWriter, error) {
+		if option == "" {
+			return nil, fmt.Errorf("%%X requires a field name, e.g. %%X{request_id}")
+		}
+		return f.fieldWriter(option), nil
+	}
+	stacktrace := func(f *PatternFormatter, option string) (patternWriter, error) {
+		return f.fieldWriter("stacktrace"), nil
+	}
+
+	patternConverters = map[string]func(*PatternFormatter, string) (patternWriter, error){
+		"d": date, "date": date,
+		"p": level, "level": level, "le": level,
+		"m": message, "msg": message, "message": message,
+		"X": field, "field": field, "mdc": field,
+		"ex": stacktrace, "stacktrace": stacktrace,
+		"c": func(f *PatternFormatter, option string) (patternWriter, error) {
+			return f.fieldWriter("logger"), nil
+		},
+		"logger": func(f *PatternFormatter, option string) (patternWriter, error) {
+			return f.fieldWriter("logger"), nil
+		},
+		"fields": func(f *PatternFormatter, option string) (patternWriter, error) {
+			switch option {
+			case "", "logfmt", "json":
+				return f.fieldsWriter(option == "json"), nil
+			}
+			return nil, fmt.Errorf("unknown %%fields option: %s", option)
+		},
+		"caller": func(f *PatternFormatter, option string) (patternWriter, error) {
+			short := option == "short"
+			return func(buf *bytes.Buffer, entry *Entry) {
+				if entry.Caller == "" {
+					return
+				}
+				if short {
+					buf.WriteString(filepath.Base(entry.Caller))
+				} else {
+					buf.WriteString(entry.Caller)
+				}
+			}, nil
+		},
+		"F": func(f *PatternFormatter, option string) (patternWriter, error) {
+			return func(buf *bytes.Buffer, entry *Entry) {
+				file, _ := splitCaller(entry.Caller)
+				buf.WriteString(file)
+			}, nil
+		},
+		"L": func(f *PatternFormatter, option string) (patternWriter, error) {
+			return func(buf *bytes.Buffer, entry *Entry) {
+				if _, line := splitCaller(entry.Caller); line > 0 {
+					buf.WriteString(strconv.Itoa(line))
+				}
+			}, nil
+		},
+		"traceid": func(f *PatternFormatter, option string) (patternWriter, error) {
+			return func(buf *bytes.Buffer, entry *Entry) {
+				traceID, _ := entryTraceIDs(entry)
+				buf.WriteString(traceID)
+			}, nil
+		},
+		"spanid": func(f *PatternFormatter, option string) (patternWriter, error) {
+			return func(buf *bytes.Buffer, entry *Entry) {
+				_, spanID := entryTraceIDs(entry)
+				buf.WriteString(spanID)
+			}, nil
+		},
+		"n": func(f *PatternFormatter, option string) (patternWriter, error) {
+			return patternLiteral("\n"), nil
+		},
+	}
+}
+
+// NewPatternFormatter compiles layout into a pattern formatter. An empty
+// layout uses DefaultPatternLayout.
+func NewPatternFormatter(layout string) (*PatternFormatter, error) {
+	if layout == "" {
+		layout = DefaultPatternLayout
+	}
+	f := &PatternFormatter{
+		Layout:   layout,
+		consumed: make(map[string]bool),
+	}
+	if err := f.compile(strings.TrimSuffix(layout, "%n")); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Format implements the Formatter interface for pattern output
+func (f *PatternFormatter) Format(entry *Entry) ([]byte, error) {
+	entry = stripMetrics(entry)
+	var buf bytes.Buffer
+	for _, w := range f.writers {
+		w(&buf, entry)
+	}
+	return bytes.TrimRight(buf.Bytes(), " "), nil
+}
+
+// compile parses layout into writers
+func (f *PatternFormatter) compile(layout string) error {
+	var literal strings.Builder
+	flush := func() {
+		if literal.Len() > 0 {
+			f.writers = append(f.writers, patternLiteral(literal.String()))
+			literal.Reset()
+		}
+	}
+
+	for i := 0; i < len(layout); {
+		if layout[i] != '%' {
+			literal.WriteByte(layout[i])
+			i++
+			continue
+		}
+		start := i
+		i++
+		if i < len(layout) && layout[i] == '%' {
+			literal.WriteByte('%')
+			i++
+			continue
+		}
+
+		// Format modifiers: [-]min[.[-]max]
+		leftAlign := i < len(layout) && layout[i] == '-'
+		if leftAlign {
+			i++
+		}
+		minWidth, n := patternNumber(layout[i:])
+		i += n
+		maxWidth, keepStart := 0, false
+		if i < len(layout) && layout[i] == '.' {
+			i++
+			if i < len(layout) && layout[i] == '-' {
+				keepStart = true
+				i++
+			}
+			maxWidth, n = patternNumber(layout[i:])
+			if n == 0 {
+				return fmt.Errorf("missing maximum width at offset %d in pattern %q", start, layout)
+			}
+			i += n
+		}
+
+		nameStart := i
+		for i < len(layout) && unicode.IsLetter(rune(layout[i])) {
+			i++
+		}
+		name := layout[nameStart:i]
+		if name == "" {
+			return fmt.Errorf("missing conversion at offset %d in pattern %q", start, layout)
+		}
+
+		option := ""
+		if i < len(layout) && layout[i] == '{' {
+			end := strings.IndexByte(layout[i:], '}')
+			if end < 0 {
+				return fmt.Errorf("unclosed option at offset %d in pattern %q", i, layout)
+			}
+			option = layout[i+1 : i+end]
+			i += end + 1
+		}
+
+		build, ok := patternConverters[name]
+		if !ok {
+			return fmt.Errorf("unknown conversion %%%s in pattern %q", name, layout)
+		}
+		w, err := build(f, option)
+		if err != nil {
+			return err
+		}
+		if minWidth > 0 || maxWidth > 0 {
+			w = patternPad(w, minWidth, maxWidth, leftAlign, keepStart)
+		}
+
+		flush()
+		f.writers = append(f.writers, w)
+	}
+	flush()
+	return nil
+}
+
+// fieldWriter writes a single field and leaves it out of %fields
+func (f *PatternFormatter) fieldWriter(key string) patternWriter {
+	f.consumed[key] = true
+	return func(buf *bytes.Buffer, entry *Entry) {
+		if v, ok := entry.Fields[key]; ok {
+			buf.WriteString(fieldString(v))
+		}
+	}
+}
+
+// fieldsWriter writes the fields not shown elsewhere, sorted by key
+func (f *PatternFormatter) fieldsWriter(asJSON bool) patternWriter {
+	return func(buf *bytes.Buffer, entry *Entry) {
+		keys := make([]string, 0, len(entry.Fields))
+		for k := range entry.Fields {
+			if !f.consumed[k] {
+				keys = append(keys, k)
+			}
+		}
+		if len(keys) == 0 {
+			if asJSON {
+				buf.WriteString("{}")
+			}
+			return
+		}
+		sort.Strings(keys)
+
+		if asJSON {
+			fields := make(map[string]interface{}, len(keys))
+			for _, k := range keys {
+				v := entry.Fields[k]
+				if err, ok := v.(error); ok {
+					v = errorValue(err)
+				}
+				fields[k] = v
+			}
+			data, err := json.Marshal(fields)
+			if err != nil {
+				data = []byte(strconv.Quote(err.Error()))
+			}
+			buf.Write(data)
+			return
+		}
+
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(' ')
+			}
+			buf.WriteString(k)
+			buf.WriteByte('=')
+			buf.WriteString(fieldString(entry.Fields[k]))
+		}
+	}
+}
+
+// patternDate returns a writer for the entry time in the given layout
+func patternDate(layout string) patternWriter {
+	switch layout {
+	case "":
+		layout = "2006-01-02T15:04:05.000Z07:00"
+	case "ISO8601":
+		layout = "2006-01-02T15:04:05.000"
+	case "RFC3339":
+		layout = time.RFC3339
+	case "UNIX":
+		return func(buf *bytes.Buffer, entry *Entry) {
+			buf.WriteString(strconv.FormatInt(entry.Time.Unix(), 10))
+		}
+	case "UNIX_MILLIS":
+		return func(buf *bytes.Buffer, entry *Entry) {
+			buf.WriteString(strconv.FormatInt(entry.Time.UnixNano()/int64(time.Millisecond), 10))
+		}
+	}
+	return func(buf *bytes.Buffer, entry *Entry) {
+		buf.WriteString(entry.Time.Format(layout))
+	}
+}
+
+// patternLiteral returns a writer for fixed text
+func patternLiteral(s string) patternWriter {
+	return func(buf *bytes.Buffer, entry *Entry) {
+		buf.WriteString(s)
+	}
+}
+
+// patternPad wraps w to pad its output to minWidth and truncate it to
+// maxWidth characters, keeping the end unless keepStart is set
+func patternPad(w patternWriter, minWidth, maxWidth int, leftAlign, keepStart bool) patternWriter {
+	return func(buf *bytes.Buffer, entry *Entry) {
+		start := buf.Len()
+		w(buf, entry)
+		s := string(buf.Bytes()[start:])
+		count := utf8.RuneCountInString(s)
+
+		if maxWidth > 0 && count > maxWidth {
+			runes := []rune(s)
+			if keepStart {
+				s = string(runes[:maxWidth])
+			} else {
+				s = string(runes[count-maxWidth:])
+			}
+			count = maxWidth
+		}
+		if count >= minWidth {
+			buf.Truncate(start)
+			buf.WriteString(s)
+			return
+		}
+
+		padding := strings.Repeat(" ", minWidth-count)
+		buf.Truncate(start)
+		if leftAlign {
+			buf.WriteString(s)
+			buf.WriteString(padding)
+		} else {
+			buf.WriteString(padding)
+			buf.WriteString(s)
+		}
+	}
+}
+
+// patternNumber parses leading digits, returning the value and their count
+func patternNumber(s string) (int, int) {
+	n := 0
+	for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+		n++
+	}
+	if n == 0 {
+		return 0, 0
+	}
+	value, _ := strconv.Atoi(s[:n])
+	return value, n
+}
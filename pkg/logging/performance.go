@@ -435,6 +435,9 @@ func (hpl *HighPerformanceLogger) SetLevel(level Level)             { hpl.level
 func (hpl *HighPerformanceLogger) SetHandler(handler Handler)       { hpl.handler = handler }
 func (hpl *HighPerformanceLogger) SetFormatter(formatter Formatter) { hpl.formatter = formatter }
 func (hpl *HighPerformanceLogger) AddHook(hook Hook)                { hpl.hooks = append(hpl.hooks, hook) }
+func (hpl *HighPerformanceLogger) Metric(name string, value float64, unit string) Logger {
+	return hpl
+}
 
 // GetBufferPoolStats returns buffer pool statistics
 func (hpl *HighPerformanceLogger) GetBufferPoolStats() BufferPoolStats {
@@ -0,0 +1,292 @@
+package logging
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Processor is a single stage of a Pipeline.
+//
+// Process may modify the entry and return it, return a different entry, or
+// return nil to drop it. Processors must not keep a reference to the entry
+// after returning, since entries are pooled and reused by the logger.
+type Processor interface {
+	Process(entry *Entry) (*Entry, error)
+}
+
+// ProcessorFunc adapts an ordinary function to the Processor interface
+type ProcessorFunc func(entry *Entry) (*Entry, error)
+
+// Process calls f(entry)
+func (f ProcessorFunc) Process(entry *Entry) (*Entry, error) {
+	return f(entry)
+}
+
+// StageErrorPolicy controls what a Pipeline does when a stage returns an error
+type StageErrorPolicy int
+
+const (
+	// StageErrorContinue passes the entry unchanged to the next stage
+	StageErrorContinue StageErrorPolicy = iota
+	// StageErrorDrop drops the entry and returns the error from Handle
+	StageErrorDrop
+)
+
+// StageOption is a functional option for pipeline stage configuration
+type StageOption func(*pipelineStage)
+
+// WithStageErrorPolicy sets how errors returned by the stage are handled
+func WithStageErrorPolicy(policy StageErrorPolicy) StageOption {
+	return func(s *pipelineStage) {
+		s.policy = policy
+	}
+}
+
+// StageStats holds counters and timing for a single pipeline stage
+type StageStats struct {
+	Name          string
+	Processed     int64
+	Dropped       int64
+	Errors        int64
+	TotalDuration time.Duration
+}
+
+// AvgDuration returns the average time spent in the stage per entry
+func (s StageStats) AvgDuration() time.Duration {
+	if s.Processed == 0 {
+		return 0
+	}
+	return s.TotalDuration / time.Duration(s.Processed)
+}
+
+// pipelineStage is a named processor with its statistics
+type pipelineStage struct {
+	name      string
+	processor Processor
+	policy    StageErrorPolicy
+	processed int64
+	dropped   int64
+	errors    int64
+	duration  int64
+}
+
+// Pipeline runs entries through an ordered list of processors before
+// passing them to a terminal handler
+type Pipeline struct {
+	handler Handler
+	stages  []*pipelineStage
+	mu      sync.RWMutex
+}
+
+// NewPipeline creates a new pipeline ending in handler
+//
+// Example:
+//
+//	pipeline := logging.NewPipeline(logging.NewConsoleHandler()).
+//	    Use("enrich", logging.EnrichProcessor(logging.Fields{"service": "api"})).
+//	    Use("redact", logging.RedactProcessor(logging.NewPIIDetector())).
+//	    Use("drop-debug", logging.FilterProcessor(func(e *logging.Entry) bool {
+//	        return e.Level.Value >= logging.InfoLevel.Value
+//	    }))
+func NewPipeline(handler Handler) *Pipeline {
+	return &Pipeline{
+		handler: handler,
+	}
+}
+
+// Use appends a named stage to the end of the pipeline
+func (p *Pipeline) Use(name string, processor Processor, opts ...StageOption) *Pipeline {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stages = append(p.stages, newPipelineStage(name, processor, opts))
+	return p
+}
+
+// InsertBefore inserts a named stage before the stage called before.
+// If no such stage exists the new stage is appended.
+func (p *Pipeline) InsertBefore(before, name string, processor Processor, opts ...StageOption) *Pipeline {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stage := newPipelineStage(name, processor, opts)
+	for i, s := range p.stages {
+		if s.name == before {
+			stages := make([]*pipelineStage, 0, len(p.stages)+1)
+			stages = append(stages, p.stages[:i]...)
+			stages = append(stages, stage)
+			p.stages = append(stages, p.stages[i:]...)
+			return p
+		}
+	}
+	p.stages = append(p.stages, stage)
+	return p
+}
+
+// Remove removes the stage with the given name and reports whether it existed
+func (p *Pipeline) Remove(name string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, s := range p.stages {
+		if s.name == name {
+			stages := make([]*pipelineStage, 0, len(p.stages)-1)
+			stages = append(stages, p.stages[:i]...)
+			p.stages = append(stages, p.stages[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// SetHandler replaces the terminal handler of the pipeline
+func (p *Pipeline) SetHandler(handler Handler) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.handler = handler
+}
+
+// Handle implements the Handler interface by running the entry through all stages
+func (p *Pipeline) Handle(entry *Entry) error {
+	p.mu.RLock()
+	stages := p.stages
+	handler := p.handler
+	p.mu.RUnlock()
+
+	for _, stage := range stages {
+		start := time.Now()
+		next, err := stage.processor.Process(entry)
+		atomic.AddInt64(&stage.duration, int64(time.Since(start)))
+		atomic.AddInt64(&stage.processed, 1)
+
+		if err != nil {
+			atomic.AddInt64(&stage.errors, 1)
+			if stage.policy == StageErrorDrop {
+				atomic.AddInt64(&stage.dropped, 1)
+				return fmt.Errorf("pipeline stage %q: %w", stage.name, err)
+			}
+			continue
+		}
+
+		if next == nil {
+			atomic.AddInt64(&stage.dropped, 1)
+			return nil
+		}
+		entry = next
+	}
+
+	if handler == nil {
+		return nil
+	}
+	return handler.Handle(entry)
+}
+
+// Stats returns statistics for every stage in pipeline order
+func (p *Pipeline) Stats() []StageStats {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	stats := make([]StageStats, 0, len(p.stages))
+	for _, s := range p.stages {
+		stats = append(stats, StageStats{
+			Name:          s.name,
+			Processed:     atomic.LoadInt64(&s.processed),
+			Dropped:       atomic.LoadInt64(&s.dropped),
+			Errors:        atomic.LoadInt64(&s.errors),
+			TotalDuration: time.Duration(atomic.LoadInt64(&s.duration)),
+		})
+	}
+	return stats
+}
+
+// newPipelineStage creates a stage with options applied
+func newPipelineStage(name string, processor Processor, opts []StageOption) *pipelineStage {
+	stage := &pipelineStage{
+		name:      name,
+		processor: processor,
+	}
+	for _, opt := range opts {
+		opt(stage)
+	}
+	return stage
+}
+
+// EnrichProcessor adds fields to every entry without overwriting existing keys
+func EnrichProcessor(fields Fields) Processor {
+	return ProcessorFunc(func(entry *Entry) (*Entry, error) {
+		enriched := make(Fields, len(entry.Fields)+len(fields))
+		for k, v := range fields {
+			enriched[k] = v
+		}
+		for k, v := range entry.Fields {
+			enriched[k] = v
+		}
+		entry.Fields = enriched
+		return entry, nil
+	})
+}
+
+// FilterProcessor drops entries for which keep returns false
+func FilterProcessor(keep func(entry *Entry) bool) Processor {
+	return ProcessorFunc(func(entry *Entry) (*Entry, error) {
+		if !keep(entry) {
+			return nil, nil
+		}
+		return entry, nil
+	})
+}
+
+// LevelFilterProcessor drops entries below level
+func LevelFilterProcessor(level Level) Processor {
+	return FilterProcessor(func(entry *Entry) bool {
+		return entry.Level.Value >= level.Value
+	})
+}
+
+// TransformProcessor applies fn to every entry
+func TransformProcessor(fn func(entry *Entry)) Processor {
+	return ProcessorFunc(func(entry *Entry) (*Entry, error) {
+		fn(entry)
+		return entry, nil
+	})
+}
+
+// RedactProcessor sanitizes PII in the message and fields of every entry
+func RedactProcessor(detector *PIIDetector) Processor {
+	return ProcessorFunc(func(entry *Entry) (*Entry, error) {
+		if detector != nil && detector.enabled {
+			entry.Message = detector.SanitizeString(entry.Message)
+			entry.Fields = detector.SanitizeFields(entry.Fields)
+		}
+		return entry, nil
+	})
+}
+
+// HookProcessor adapts a Hook to the Processor interface
+func HookProcessor(hook Hook) Processor {
+	return TransformProcessor(func(entry *Entry) {
+		hook(entry)
+	})
+}
+
+// processAndHandle runs entry through processor and passes the result to handler
+func processAndHandle(processor Processor, handler Handler, entry *Entry) error {
+	next, err := processor.Process(entry)
+	if err != nil || next == nil {
+		return err
+	}
+	return handler.Handle(next)
+}
+
+// cloneFields returns a copy of fields that is safe to modify.
+//
+// Entry fields are shared with the logger that produced them, so processors
+// and handlers must not add keys to them in place.
+func cloneFields(fields Fields) Fields {
+	cloned := make(Fields, len(fields)+4)
+	for k, v := range fields {
+		cloned[k] = v
+	}
+	return cloned
+}
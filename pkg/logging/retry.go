@@ -0,0 +1,226 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned by CircuitBreaker while the circuit is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// HTTPStatusError is returned by network handlers for error status codes
+type HTTPStatusError struct {
+	StatusCode int
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("HTTP request failed with status: %d", e.StatusCode)
+}
+
+// permanentError marks an error as not worth retrying
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so RetryHandler does not retry it
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsRetryable is the default retry classification. Errors wrapped with
+// Permanent, ErrCircuitOpen and HTTP 4xx statuses other than 408 and 429
+// are not retried; everything else is.
+func IsRetryable(err error) bool {
+	var permanent *permanentError
+	if errors.As(err, &permanent) || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+
+	var status *HTTPStatusError
+	if errors.As(err, &status) && status.StatusCode >= 400 && status.StatusCode < 500 {
+		return status.StatusCode == http.StatusRequestTimeout || status.StatusCode == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// RetryConfig represents retry handler configuration
+type RetryConfig struct {
+	// MaxAttempts is the total number of attempts, including the first (default 3)
+	MaxAttempts int
+
+	// InitialBackoff is the wait before the first retry (default 100ms)
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the wait between attempts (default 5s)
+	MaxBackoff time.Duration
+
+	// Multiplier grows the backoff after each retry (default 2)
+	Multiplier float64
+
+	// Jitter randomizes each wait by up to this fraction, e.g. 0.2 for ±20%
+	Jitter float64
+
+	// Retryable classifies errors (default IsRetryable)
+	Retryable func(err error) bool
+
+	// DeadLetter receives entries that exhaust their attempts or fail with a
+	// non-retryable error, with dead_letter_error and dead_letter_attempts fields
+	DeadLetter Handler
+}
+
+// RetryStats represents retry handler counters
+type RetryStats struct {
+	Handled      int64
+	Retries      int64
+	Failed       int64
+	DeadLettered int64
+}
+
+// RetryHandler retries a failing handler with exponential backoff.
+//
+// Retries block the caller; wrap the RetryHandler in an AsyncHandler to keep
+// them off the logging path. Backoff waits end early when the entry's
+// context is done. Wrapping a CircuitBreakerHandler stops retries
+// as soon as the circuit opens, since ErrCircuitOpen is not retryable.
+type RetryHandler struct {
+	handler Handler
+	config  RetryConfig
+	stats   RetryStats
+	mu      sync.Mutex
+}
+
+// NewRetryHandler creates a new retry handler around handler
+func NewRetryHandler(handler Handler, config RetryConfig) *RetryHandler {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 3
+	}
+	if config.InitialBackoff <= 0 {
+		config.InitialBackoff = 100 * time.Millisecond
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = 5 * time.Second
+	}
+	if config.Multiplier < 1 {
+		config.Multiplier = 2
+	}
+	if config.Retryable == nil {
+		config.Retryable = IsRetryable
+	}
+
+	return &RetryHandler{
+		handler: handler,
+		config:  config,
+	}
+}
+
+// Handle implements the Handler interface with retries. It returns nil when
+// the entry was delivered or accepted by the dead-letter handler.
+func (h *RetryHandler) Handle(entry *Entry) error {
+	backoff := h.config.InitialBackoff
+
+	var err error
+	attempt := 1
+	for ; ; attempt++ {
+		if err = h.handler.Handle(entry); err == nil {
+			h.count(func(s *RetryStats) { s.Handled++ })
+			return nil
+		}
+		if attempt >= h.config.MaxAttempts || !h.config.Retryable(err) {
+			break
+		}
+		if entry.Context != nil && entry.Context.Err() != nil {
+			break
+		}
+
+		h.count(func(s *RetryStats) { s.Retries++ })
+		if !h.wait(entry, h.jitter(backoff)) {
+			break
+		}
+
+		backoff = time.Duration(float64(backoff) * h.config.Multiplier)
+		if backoff > h.config.MaxBackoff {
+			backoff = h.config.MaxBackoff
+		}
+	}
+
+	h.count(func(s *RetryStats) { s.Failed++ })
+	err = fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+
+	if h.config.DeadLetter == nil {
+		return err
+	}
+
+	dead := entry.Clone()
+	if dead.Fields == nil {
+		dead.Fields = make(Fields, 2)
+	}
+	dead.Fields["dead_letter_error"] = err.Error()
+	dead.Fields["dead_letter_attempts"] = attempt
+	if dlErr := h.config.DeadLetter.Handle(dead); dlErr != nil {
+		return errors.Join(err, fmt.Errorf("dead letter: %w", dlErr))
+	}
+
+	h.count(func(s *RetryStats) { s.DeadLettered++ })
+	return nil
+}
+
+// Stats returns a snapshot of the retry counters
+func (h *RetryHandler) Stats() RetryStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.stats
+}
+
+// count updates the counters under the lock
+func (h *RetryHandler) count(update func(*RetryStats)) {
+	h.mu.Lock()
+	update(&h.stats)
+	h.mu.Unlock()
+}
+
+// wait sleeps for d, returning false if the entry's context is done first
+func (h *RetryHandler) wait(entry *Entry, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	if entry.Context == nil {
+		<-timer.C
+		return true
+	}
+	select {
+	case <-timer.C:
+		return true
+	case <-entry.Context.Done():
+		return false
+	}
+}
+
+// jitter randomizes d by up to ±Jitter
+func (h *RetryHandler) jitter(d time.Duration) time.Duration {
+	if h.config.Jitter <= 0 {
+		return d
+	}
+	delta := h.config.Jitter * (2*rand.Float64() - 1)
+	return time.Duration(float64(d) * (1 + delta))
+}
+
+// NewDeadLetterFileHandler creates a file handler that writes one JSON object
+// per line, suitable as RetryConfig.DeadLetter
+func NewDeadLetterFileHandler(filename string, opts ...FileOption) (Handler, error) {
+	handler, err := NewFileHandler(filename, opts...)
+	if err != nil {
+		return nil, err
+	}
+	handler.(*FileHandler).SetFormatter(NewJSONFormatter())
+	return handler, nil
+}
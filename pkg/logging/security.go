@@ -150,6 +150,7 @@ func NewSecurityFormatter(formatter Formatter, detector *PIIDetector) Formatter
 
 // Format formats the entry and sanitizes the output
 func (sf *SecurityFormatter) Format(entry *Entry) ([]byte, error) {
+	entry = stripMetrics(entry)
 	// Create a copy of the entry for sanitization
 	sanitizedEntry := &Entry{
 		Level:   entry.Level,
@@ -0,0 +1,383 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// SIEMEventIDField is the entry field that overrides the configured CEF
+// event class ID or LEEF event ID
+const SIEMEventIDField = "event_id"
+
+// leefTimeFormat is the Java layout written in devTimeFormat and the
+// matching Go layout used for devTime
+const (
+	leefTimeFormat   = "MMM dd yyyy HH:mm:ss.SSS z"
+	leefTimeLayoutGo = "Jan 02 2006 15:04:05.000 MST"
+)
+
+// DefaultCEFFieldMap maps common field names to CEF extension keys
+var DefaultCEFFieldMap = map[string]string{
+	"src_ip":      "src",
+	"remote_ip":   "src",
+	"remote_addr": "src",
+	"client_ip":   "src",
+	"dst_ip":      "dst",
+	"src_port":    "spt",
+	"dst_port":    "dpt",
+	"user":        "suser",
+	"username":    "suser",
+	"user_id":     "suid",
+	"method":      "requestMethod",
+	"url":         "request",
+	"path":        "request",
+	"user_agent":  "requestClientApplication",
+	"action":      "act",
+	"outcome":     "outcome",
+	"protocol":    "app",
+	"hostname":    "dhost",
+	"request_id":  "externalId",
+}
+
+// DefaultLEEFFieldMap maps common field names to LEEF predefined attributes
+var DefaultLEEFFieldMap = map[string]string{
+	"src_ip":      "src",
+	"remote_ip":   "src",
+	"remote_addr": "src",
+	"client_ip":   "src",
+	"dst_ip":      "dst",
+	"src_port":    "srcPort",
+	"dst_port":    "dstPort",
+	"user":        "usrName",
+	"username":    "usrName",
+	"protocol":    "proto",
+	"url":         "url",
+	"path":        "url",
+	"category":    "cat",
+	"hostname":    "identHostName",
+}
+
+// CEFFormatterOption is a functional option for CEFFormatter configuration.
+type CEFFormatterOption func(*CEFFormatter)
+
+// CEFFormatter formats log entries as ArcSight Common Event Format:
+//
+//	CEF:0|Vendor|Product|Version|EventClassID|Message|Severity|rt=... key=value
+//
+// Fields listed in FieldMap are written under their CEF extension key, other
+// fields under a camelCase form of their name with nested maps flattened.
+// An event_id field overrides EventClassID for a single entry.
+type CEFFormatter struct {
+	Vendor       string
+	Product      string
+	Version      string
+	EventClassID string
+
+	// FieldMap maps field names to extension keys (default DefaultCEFFieldMap)
+	FieldMap map[string]string
+}
+
+// NewCEFFormatter creates a new CEF formatter with options.
+func NewCEFFormatter(opts ...CEFFormatterOption) Formatter {
+	f := &CEFFormatter{
+		Vendor:       "go-logging",
+		Product:      "go-logging",
+		Version:      "1.0",
+		EventClassID: "log",
+		FieldMap:     DefaultCEFFieldMap,
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// WithCEFProduct sets the device vendor, product and version.
+func WithCEFProduct(vendor, product, version string) CEFFormatterOption {
+	return func(f *CEFFormatter) {
+		f.Vendor = vendor
+		f.Product = product
+		f.Version = version
+	}
+}
+
+// WithCEFEventClassID sets the default signature ID.
+func WithCEFEventClassID(id string) CEFFormatterOption {
+	return func(f *CEFFormatter) {
+		f.EventClassID = id
+	}
+}
+
+// WithCEFFieldMap replaces the field to extension key mapping.
+func WithCEFFieldMap(mapping map[string]string) CEFFormatterOption {
+	return func(f *CEFFormatter) {
+		f.FieldMap = mapping
+	}
+}
+
+// CEFSeverity maps a level to a CEF severity from 0 to 10
+func CEFSeverity(level Level) int {
+	switch {
+	case level.Value >= PanicLevel.Value:
+		return 10
+	case level.Value >= FatalLevel.Value:
+		return 9
+	case level.Value >= ErrorLevel.Value:
+		return 7
+	case level.Value >= WarnLevel.Value:
+		return 5
+	case level.Value >= InfoLevel.Value:
+		return 3
+	case level.Value >= DebugLevel.Value:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Format implements the Formatter interface for CEF output
+func (f *CEFFormatter) Format(entry *Entry) ([]byte, error) {
+	entry = stripMetrics(entry)
+	var buf bytes.Buffer
+	buf.WriteString("CEF:0|")
+	for _, h := range []string{
+		f.Vendor,
+		f.Product,
+		f.Version,
+		siemEventID(entry, f.EventClassID),
+		entry.Message,
+		strconv.Itoa(CEFSeverity(entry.Level)),
+	} {
+		buf.WriteString(siemHeaderEscape(h))
+		buf.WriteByte('|')
+	}
+
+	buf.WriteString("rt=")
+	buf.WriteString(strconv.FormatInt(entry.Time.UnixNano()/int64(time.Millisecond), 10))
+	for _, pair := range siemExtensions(entry, f.FieldMap) {
+		buf.WriteByte(' ')
+		buf.WriteString(pair[0])
+		buf.WriteByte('=')
+		buf.WriteString(cefExtensionEscape(pair[1]))
+	}
+
+	return buf.Bytes(), nil
+}
+
+// LEEFFormatterOption is a functional option for LEEFFormatter configuration.
+type LEEFFormatterOption func(*LEEFFormatter)
+
+// LEEFFormatter formats log entries as IBM QRadar LEEF 2.0:
+//
+//	LEEF:2.0|Vendor|Product|Version|EventID|x09|devTime=...<tab>sev=...<tab>key=value
+//
+// Fields are mapped like CEFFormatter using FieldMap. LEEF has no escape
+// sequences for attribute values, so the delimiter and line breaks in values
+// are replaced by spaces.
+type LEEFFormatter struct {
+	Vendor  string
+	Product string
+	Version string
+	EventID string
+
+	// Delimiter separates attributes (default tab)
+	Delimiter rune
+
+	// FieldMap maps field names to attributes (default DefaultLEEFFieldMap)
+	FieldMap map[string]string
+}
+
+// NewLEEFFormatter creates a new LEEF 2.0 formatter with options.
+func NewLEEFFormatter(opts ...LEEFFormatterOption) Formatter {
+	f := &LEEFFormatter{
+		Vendor:    "go-logging",
+		Product:   "go-logging",
+		Version:   "1.0",
+		EventID:   "log",
+		Delimiter: '\t',
+		FieldMap:  DefaultLEEFFieldMap,
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// WithLEEFProduct sets the vendor, product and version.
+func WithLEEFProduct(vendor, product, version string) LEEFFormatterOption {
+	return func(f *LEEFFormatter) {
+		f.Vendor = vendor
+		f.Product = product
+		f.Version = version
+	}
+}
+
+// WithLEEFEventID sets the default event ID.
+func WithLEEFEventID(id string) LEEFFormatterOption {
+	return func(f *LEEFFormatter) {
+		f.EventID = id
+	}
+}
+
+// WithLEEFDelimiter sets the attribute delimiter, e.g. '^'.
+func WithLEEFDelimiter(delimiter rune) LEEFFormatterOption {
+	return func(f *LEEFFormatter) {
+		f.Delimiter = delimiter
+	}
+}
+
+// WithLEEFFieldMap replaces the field to attribute mapping.
+func WithLEEFFieldMap(mapping map[string]string) LEEFFormatterOption {
+	return func(f *LEEFFormatter) {
+		f.FieldMap = mapping
+	}
+}
+
+// Format implements the Formatter interface for LEEF output
+func (f *LEEFFormatter) Format(entry *Entry) ([]byte, error) {
+	entry = stripMetrics(entry)
+	delimiter := f.Delimiter
+	if delimiter == 0 {
+		delimiter = '\t'
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("LEEF:2.0|")
+	for _, h := range []string{f.Vendor, f.Product, f.Version, siemEventID(entry, f.EventID)} {
+		buf.WriteString(siemHeaderEscape(h))
+		buf.WriteByte('|')
+	}
+	if delimiter < ' ' || delimiter == '|' {
+		fmt.Fprintf(&buf, "x%02X|", delimiter)
+	} else {
+		buf.WriteRune(delimiter)
+		buf.WriteByte('|')
+	}
+
+	sev := CEFSeverity(entry.Level)
+	if sev < 1 {
+		sev = 1
+	}
+	pairs := [][2]string{
+		{"devTime", entry.Time.Format(leefTimeLayoutGo)},
+		{"devTimeFormat", leefTimeFormat},
+		{"sev", strconv.Itoa(sev)},
+		{"msg", entry.Message},
+	}
+	pairs = append(pairs, siemExtensions(entry, f.FieldMap)...)
+
+	clean := strings.NewReplacer(string(delimiter), " ", "\r\n", " ", "\n", " ", "\r", " ")
+	for i, pair := range pairs {
+		if i > 0 {
+			buf.WriteRune(delimiter)
+		}
+		buf.WriteString(pair[0])
+		buf.WriteByte('=')
+		buf.WriteString(clean.Replace(pair[1]))
+	}
+
+	return buf.Bytes(), nil
+}
+
+// siemEventID returns the entry's event_id field or the default ID
+func siemEventID(entry *Entry, id string) string {
+	if v, ok := entry.Fields[SIEMEventIDField]; ok {
+		return fmt.Sprint(v)
+	}
+	return id
+}
+
+// siemExtensions returns the entry's fields as key/value pairs sorted by
+// key, using fieldMap for known fields and camelCase keys for the rest
+func siemExtensions(entry *Entry, fieldMap map[string]string) [][2]string {
+	keys := make([]string, 0, len(entry.Fields))
+	for k := range entry.Fields {
+		if k != SIEMEventIDField {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	values := make(map[string]string, len(keys))
+	var add func(key string, value interface{})
+	add = func(key string, value interface{}) {
+		var nested map[string]interface{}
+		switch v := value.(type) {
+		case Fields:
+			nested = v
+		case map[string]interface{}:
+			nested = v
+		}
+		if nested == nil {
+			values[siemKey(key)] = siemValue(value)
+			return
+		}
+		for k, v := range nested {
+			add(key+"."+k, v)
+		}
+	}
+	for _, k := range keys {
+		if mapped := fieldMap[k]; mapped != "" {
+			values[mapped] = siemValue(entry.Fields[k])
+			continue
+		}
+		add(k, entry.Fields[k])
+	}
+
+	pairs := make([][2]string, 0, len(values))
+	for k, v := range values {
+		if k != "" {
+			pairs = append(pairs, [2]string{k, v})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i][0] < pairs[j][0] })
+	return pairs
+}
+
+// siemKey turns a field name such as "order_id" or "user.name" into an
+// alphanumeric camelCase key such as "orderId" or "userName"
+func siemKey(name string) string {
+	var b strings.Builder
+	upper := false
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) || r > unicode.MaxASCII {
+			upper = b.Len() > 0
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// siemValue renders a field value as a string
+func siemValue(value interface{}) string {
+	if t, ok := value.(time.Time); ok {
+		return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+	}
+	return fieldString(value)
+}
+
+// siemHeaderEscape escapes backslashes and pipes in a CEF or LEEF header
+// field and replaces line breaks, which are not allowed there
+func siemHeaderEscape(s string) string {
+	return siemHeaderReplacer.Replace(s)
+}
+
+var siemHeaderReplacer = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r\n", " ", "\n", " ", "\r", " ")
+
+// cefExtensionEscape escapes backslashes, equal signs and line breaks in a
+// CEF extension value
+func cefExtensionEscape(s string) string {
+	return cefExtensionReplacer.Replace(s)
+}
+
+var cefExtensionReplacer = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r\n", `\n`, "\n", `\n`, "\r", `\r`)
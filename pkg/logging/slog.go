@@ -224,3 +224,6 @@ func (sw *slogWrapper) SetLevel(level Level)                   {}            //
 func (sw *slogWrapper) SetHandler(handler Handler)             {}            // Simplified
 func (sw *slogWrapper) SetFormatter(formatter Formatter)       {}            // Simplified
 func (sw *slogWrapper) AddHook(hook Hook)                      {}            // Simplified
+func (sw *slogWrapper) Metric(name string, value float64, unit string) Logger {
+	return sw // Simplified
+}
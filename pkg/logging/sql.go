@@ -0,0 +1,357 @@
+package logging
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SQL dialects supported by SQLHandler
+const (
+	SQLDialectSQLite   = "sqlite"
+	SQLDialectPostgres = "postgres"
+	SQLDialectMySQL    = "mysql"
+)
+
+// sqlColumns are the columns written for every entry, in insert order
+var sqlColumns = []string{"logged_at", "level", "level_value", "message", "caller", "fields"}
+
+// sqlTableName matches the table names SQLHandler accepts
+var sqlTableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SQLConfig represents database/sql handler configuration
+type SQLConfig struct {
+	// DB is an open database handle; the caller registers the driver
+	DB *sql.DB
+
+	// Dialect is SQLDialectSQLite, SQLDialectPostgres or SQLDialectMySQL
+	Dialect string
+
+	// Table is the table entries are inserted into (default "logs")
+	Table string
+
+	// SkipSchema disables CREATE TABLE IF NOT EXISTS on startup
+	SkipSchema bool
+
+	// BatchSize is the number of rows per INSERT (default 100)
+	BatchSize int
+
+	// FlushInterval is the maximum time an entry waits before insert (default 2s)
+	FlushInterval time.Duration
+
+	// MaxQueueSize caps buffered entries; the oldest are dropped (default 10 * BatchSize)
+	MaxQueueSize int
+
+	// Retention deletes rows older than this (0 = keep forever)
+	Retention time.Duration
+
+	// PurgeInterval is how often the retention purge runs (default 1 hour)
+	PurgeInterval time.Duration
+
+	// OnError is called when a background insert or purge fails
+	OnError func(err error)
+}
+
+// SQLHandler inserts entries into a SQL table in batches
+type SQLHandler struct {
+	config  SQLConfig
+	table   string
+	queue   []*Entry
+	dropped int64
+	mu      sync.Mutex
+	writeMu sync.Mutex
+	flush   chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewSQLHandler creates a new SQL handler, creating the table unless
+// SkipSchema is set, and starts its flush and purge loop
+func NewSQLHandler(config SQLConfig) (*SQLHandler, error) {
+	if config.DB == nil {
+		return nil, fmt.Errorf("SQL handler requires a database")
+	}
+	switch config.Dialect {
+	case SQLDialectSQLite, SQLDialectPostgres, SQLDialectMySQL:
+	default:
+		return nil, fmt.Errorf("unsupported SQL dialect: %q", config.Dialect)
+	}
+	if config.Table == "" {
+		config.Table = "logs"
+	}
+	if !sqlTableName.MatchString(config.Table) {
+		return nil, fmt.Errorf("invalid SQL table name: %q", config.Table)
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	// SQLite limits a statement to 999 parameters in older builds
+	if maxRows := 999 / len(sqlColumns); config.BatchSize > maxRows {
+		config.BatchSize = maxRows
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = 2 * time.Second
+	}
+	if config.MaxQueueSize <= 0 {
+		config.MaxQueueSize = 10 * config.BatchSize
+	}
+	if config.PurgeInterval <= 0 {
+		config.PurgeInterval = time.Hour
+	}
+
+	h := &SQLHandler{
+		config: config,
+		table:  quoteSQLIdent(config.Dialect, config.Table),
+		flush:  make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	if !config.SkipSchema {
+		if err := h.createSchema(); err != nil {
+			return nil, fmt.Errorf("failed to create log table: %w", err)
+		}
+	}
+
+	go h.run()
+	return h, nil
+}
+
+// Handle implements the Handler interface by queueing the entry for insert
+func (h *SQLHandler) Handle(entry *Entry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.queue = append(h.queue, entry.Clone())
+	h.trimQueue()
+
+	if len(h.queue) >= h.config.BatchSize {
+		select {
+		case h.flush <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Flush inserts all queued entries. A batch whose insert fails is put back
+// at the front of the queue for the next flush.
+func (h *SQLHandler) Flush() error {
+	h.writeMu.Lock()
+	defer h.writeMu.Unlock()
+
+	for {
+		h.mu.Lock()
+		n := len(h.queue)
+		if n > h.config.BatchSize {
+			n = h.config.BatchSize
+		}
+		batch := h.queue[:n:n]
+		h.queue = h.queue[n:]
+		h.mu.Unlock()
+
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := h.insert(batch); err != nil {
+			h.mu.Lock()
+			h.queue = append(batch, h.queue...)
+			h.trimQueue()
+			h.mu.Unlock()
+			return fmt.Errorf("failed to insert log entries: %w", err)
+		}
+	}
+}
+
+// trimQueue drops the oldest entries beyond MaxQueueSize. It must be
+// called with h.mu held.
+func (h *SQLHandler) trimQueue() {
+	if overflow := len(h.queue) - h.config.MaxQueueSize; overflow > 0 {
+		h.queue = h.queue[overflow:]
+		h.dropped += int64(overflow)
+	}
+}
+
+// Purge deletes rows older than the configured retention
+func (h *SQLHandler) Purge() (int64, error) {
+	if h.config.Retention <= 0 {
+		return 0, nil
+	}
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE logged_at < %s", h.table, sqlPlaceholder(h.config.Dialect, 1))
+	result, err := h.config.DB.Exec(query, time.Now().Add(-h.config.Retention).UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge log entries: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// Dropped returns the number of entries dropped because the queue was full
+func (h *SQLHandler) Dropped() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.dropped
+}
+
+// Close stops the background loop and inserts any queued entries.
+// The database handle is left open.
+func (h *SQLHandler) Close() error {
+	h.once.Do(func() {
+		close(h.stop)
+		<-h.done
+	})
+	return h.Flush()
+}
+
+// run flushes when a batch is full or every FlushInterval, and purges
+// every PurgeInterval
+func (h *SQLHandler) run() {
+	defer close(h.done)
+
+	ticker := time.NewTicker(h.config.FlushInterval)
+	defer ticker.Stop()
+
+	var purge <-chan time.Time
+	if h.config.Retention > 0 {
+		purgeTicker := time.NewTicker(h.config.PurgeInterval)
+		defer purgeTicker.Stop()
+		purge = purgeTicker.C
+	}
+
+	for {
+		var err error
+		select {
+		case <-ticker.C:
+			err = h.Flush()
+		case <-h.flush:
+			err = h.Flush()
+		case <-purge:
+			_, err = h.Purge()
+		case <-h.stop:
+			return
+		}
+		if err != nil && h.config.OnError != nil {
+			h.config.OnError(err)
+		}
+	}
+}
+
+// insert writes a batch with a single multi-row INSERT
+func (h *SQLHandler) insert(batch []*Entry) error {
+	var query strings.Builder
+	fmt.Fprintf(&query, "INSERT INTO %s (%s) VALUES ", h.table, strings.Join(sqlColumns, ", "))
+
+	args := make([]interface{}, 0, len(batch)*len(sqlColumns))
+	for i, entry := range batch {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		query.WriteString("(")
+		for j := range sqlColumns {
+			if j > 0 {
+				query.WriteString(", ")
+			}
+			query.WriteString(sqlPlaceholder(h.config.Dialect, len(args)+j+1))
+		}
+		query.WriteString(")")
+
+		fields, err := marshalSQLFields(stripMetrics(entry).Fields)
+		if err != nil {
+			return err
+		}
+		args = append(args, entry.Time.UTC(), entry.Level.String(), entry.Level.Value, entry.Message, entry.Caller, fields)
+	}
+
+	_, err := h.config.DB.Exec(query.String(), args...)
+	return err
+}
+
+// createSchema creates the log table and its time index if they do not exist
+func (h *SQLHandler) createSchema() error {
+	var id, fieldsType string
+	switch h.config.Dialect {
+	case SQLDialectPostgres:
+		id, fieldsType = "BIGSERIAL PRIMARY KEY", "JSONB"
+	case SQLDialectMySQL:
+		id, fieldsType = "BIGINT AUTO_INCREMENT PRIMARY KEY", "JSON"
+	default:
+		id, fieldsType = "INTEGER PRIMARY KEY AUTOINCREMENT", "TEXT"
+	}
+
+	timeType := "TIMESTAMP"
+	if h.config.Dialect == SQLDialectMySQL {
+		timeType = "DATETIME(6)"
+	}
+
+	index := quoteSQLIdent(h.config.Dialect, h.config.Table+"_logged_at_idx")
+	table := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id %s,
+	logged_at %s NOT NULL,
+	level VARCHAR(32) NOT NULL,
+	level_value INTEGER NOT NULL,
+	message TEXT NOT NULL,
+	caller VARCHAR(512) NOT NULL,
+	fields %s`, h.table, id, timeType, fieldsType)
+
+	// MySQL has no CREATE INDEX IF NOT EXISTS, so its index is declared inline
+	if h.config.Dialect == SQLDialectMySQL {
+		table += fmt.Sprintf(",\n\tINDEX %s (logged_at)\n)", index)
+		_, err := h.config.DB.Exec(table)
+		return err
+	}
+
+	if _, err := h.config.DB.Exec(table + "\n)"); err != nil {
+		return err
+	}
+	_, err := h.config.DB.Exec(fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (logged_at)", index, h.table))
+	return err
+}
+
+// quoteSQLIdent quotes an identifier for dialect
+func quoteSQLIdent(dialect, name string) string {
+	if dialect == SQLDialectMySQL {
+		return "`" + name + "`"
+	}
+	return `"` + name + `"`
+}
+
+// sqlPlaceholder returns the n-th (1-based) bind parameter for dialect
+func sqlPlaceholder(dialect string, n int) string {
+	if dialect == SQLDialectPostgres {
+		return fmt.Sprintf("$%d", n)
+	}
+	return "?"
+}
+
+// marshalSQLFields encodes fields for the JSON column, rendering errors
+// and other values encoding/json cannot handle as strings
+func marshalSQLFields(fields Fields) (string, error) {
+	if len(fields) == 0 {
+		return "{}", nil
+	}
+
+	safe := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		switch val := v.(type) {
+		case error:
+			safe[k] = errorValue(val)
+		default:
+			if _, err := json.Marshal(val); err != nil {
+				safe[k] = fmt.Sprint(val)
+			} else {
+				safe[k] = val
+			}
+		}
+	}
+
+	data, err := json.Marshal(safe)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,126 @@
+package logging
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// StacktraceField is the field that holds the StackTrace captured for
+// error, fatal and panic entries when WithStacktrace is enabled
+const StacktraceField = "stacktrace"
+
+// Frame is a single call in a captured stack trace
+type Frame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// String returns the frame as "function file:line"
+func (fr Frame) String() string {
+	return fr.Function + " " + fr.File + ":" + strconv.Itoa(fr.Line)
+}
+
+// StackTrace is a list of frames, innermost call first.
+//
+// JSON formatters write it as an array of {"function", "file", "line"}
+// objects; text formats use String, which renders one function per line
+// with its file:line indented below it.
+type StackTrace []Frame
+
+// String renders the stack as a compact indented text block
+func (st StackTrace) String() string {
+	var b strings.Builder
+	for i, fr := range st {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(fr.Function)
+		b.WriteString("\n\t")
+		b.WriteString(fr.File)
+		b.WriteByte(':')
+		b.WriteString(strconv.Itoa(fr.Line))
+	}
+	return b.String()
+}
+
+// runtimeFormat renders the stack in the layout of runtime.Stack, which
+// tools such as Error Reporting parse. The goroutine ID is not recorded,
+// so it is always 1.
+func (st StackTrace) runtimeFormat() string {
+	var b strings.Builder
+	b.WriteString("goroutine 1 [running]:")
+	for _, fr := range st {
+		b.WriteByte('\n')
+		b.WriteString(fr.Function)
+		b.WriteString("(...)\n\t")
+		b.WriteString(fr.File)
+		b.WriteByte(':')
+		b.WriteString(strconv.Itoa(fr.Line))
+	}
+	return b.String()
+}
+
+// StackFilter reports whether a frame should be kept in a captured stack
+type StackFilter func(Frame) bool
+
+// packagePrefix is the function name prefix of frames inside this package
+var packagePrefix = func() string {
+	pc, _, _, _ := runtime.Caller(0)
+	name := runtime.FuncForPC(pc).Name()
+	return name[:strings.LastIndex(name, ".")+1]
+}()
+
+// DefaultStackFilter drops Go runtime and testing frames and the frames of
+// this package, leaving the application calls that led to the log entry.
+func DefaultStackFilter(fr Frame) bool {
+	switch {
+	case strings.HasPrefix(fr.Function, "runtime."),
+		strings.HasPrefix(fr.Function, "testing."):
+		return false
+	case strings.HasPrefix(fr.Function, packagePrefix):
+		// Keep the package's own tests
+		return strings.HasSuffix(fr.File, "_test.go")
+	}
+	return true
+}
+
+// CaptureStack returns the stack of the calling goroutine, starting at the
+// caller of CaptureStack. skip drops that many further frames, filter
+// decides which of the remaining frames are kept (nil keeps all of them)
+// and maxFrames limits the result (0 means no limit).
+func CaptureStack(skip int, filter StackFilter, maxFrames int) StackTrace {
+	return captureStack(skip+1, filter, maxFrames)
+}
+
+// captureStack collects the frames starting at its caller, skipping the
+// first skip of them
+func captureStack(skip int, filter StackFilter, maxFrames int) StackTrace {
+	pcs := make([]uintptr, 64)
+	for {
+		n := runtime.Callers(skip+2, pcs)
+		if n < len(pcs) {
+			pcs = pcs[:n]
+			break
+		}
+		pcs = make([]uintptr, len(pcs)*2)
+	}
+
+	var st StackTrace
+	frames := runtime.CallersFrames(pcs)
+	for {
+		f, more := frames.Next()
+		fr := Frame{Function: f.Function, File: f.File, Line: f.Line}
+		if f.PC != 0 && (filter == nil || filter(fr)) {
+			st = append(st, fr)
+			if maxFrames > 0 && len(st) == maxFrames {
+				break
+			}
+		}
+		if !more {
+			break
+		}
+	}
+	return st
+}
@@ -73,6 +73,9 @@ func (l *logger) WithTrace(ctx context.Context) Logger {
 		return l
 	}
 
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
 	fields := make(Fields)
 	for k, v := range l.fields {
 		fields[k] = v
@@ -94,13 +97,10 @@ func (l *logger) WithTrace(ctx context.Context) Logger {
 		fields["session_id"] = tc.SessionID
 	}
 
-	return &logger{
-		level:     l.level,
-		handler:   l.handler,
-		formatter: l.formatter,
-		fields:    fields,
-		hooks:     l.hooks,
-	}
+	child := l.clone()
+	child.fields = fields
+	child.ctx = ctx
+	return child
 }
 
 // NewTraceContext creates a new trace context
@@ -0,0 +1,666 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+// WebhookRenderer renders a group of entries into a webhook request body
+type WebhookRenderer interface {
+	Render(msg *WebhookMessage) ([]byte, error)
+}
+
+// WebhookRendererFunc adapts an ordinary function to the WebhookRenderer interface
+type WebhookRendererFunc func(msg *WebhookMessage) ([]byte, error)
+
+// Render calls f(msg)
+func (f WebhookRendererFunc) Render(msg *WebhookMessage) ([]byte, error) {
+	return f(msg)
+}
+
+// WebhookMessage is the data passed to renderers and payload templates
+type WebhookMessage struct {
+	Entries []WebhookEntry
+	// Level is the highest level among Entries
+	Level string
+	// Color is the hex color ("#rrggbb") of Level
+	Color string
+	// Suppressed is the number of entries dropped by throttling, MaxPending
+	// or failed deliveries since the last message
+	Suppressed int
+}
+
+// WebhookEntry is a single entry prepared for rendering
+type WebhookEntry struct {
+	Level   string
+	Color   string
+	Message string
+	Time    time.Time
+	Caller  string
+	Fields  []WebhookField
+}
+
+// WebhookField is a field rendered as a string, in sorted key order
+type WebhookField struct {
+	Key   string
+	Value string
+}
+
+// WebhookConfig represents chat webhook handler configuration
+type WebhookConfig struct {
+	URL     string
+	Headers map[string]string
+
+	// Renderer builds the request body (default: plain JSON of the WebhookMessage)
+	Renderer WebhookRenderer
+
+	// MinLevel is the lowest level that is sent (default WarnLevel)
+	MinLevel Level
+
+	// GroupWindow collects entries arriving within the window into one message (default 5s)
+	GroupWindow time.Duration
+
+	// MaxEntriesPerMessage sends a message early once this many entries are
+	// grouped; larger groups are split into several messages (default 10)
+	MaxEntriesPerMessage int
+
+	// MaxPending caps the entries waiting for delivery, including those kept
+	// after a failed request; the oldest are dropped and reported as
+	// suppressed (default 10 * MaxEntriesPerMessage)
+	MaxPending int
+
+	// MaxMessagesPerMinute throttles delivery; extra entries are dropped and
+	// reported in the next message (0 = unlimited)
+	MaxMessagesPerMinute int
+
+	// OnError is called when a message cannot be delivered. Entries of
+	// messages that failed with a retryable error are kept and sent again
+	// with the next group.
+	OnError func(err error)
+
+	Client *http.Client
+}
+
+// WebhookHandler posts entries to a chat webhook, grouping bursts into a
+// single message and throttling the message rate
+type WebhookHandler struct {
+	config     WebhookConfig
+	pending    []*Entry
+	suppressed int
+	sent       []time.Time
+	timer      *time.Timer
+	retrying   bool
+	mu         sync.Mutex
+	sendMu     sync.Mutex
+}
+
+// NewWebhookHandler creates a new generic webhook handler
+func NewWebhookHandler(config WebhookConfig) *WebhookHandler {
+	if config.Renderer == nil {
+		config.Renderer = WebhookRendererFunc(func(msg *WebhookMessage) ([]byte, error) {
+			return json.Marshal(msg)
+		})
+	}
+	if config.MinLevel.Name == "" {
+		config.MinLevel = WarnLevel
+	}
+	if config.GroupWindow <= 0 {
+		config.GroupWindow = 5 * time.Second
+	}
+	if config.MaxEntriesPerMessage <= 0 {
+		config.MaxEntriesPerMessage = 10
+	}
+	if config.MaxPending <= 0 {
+		config.MaxPending = 10 * config.MaxEntriesPerMessage
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &WebhookHandler{
+		config: config,
+	}
+}
+
+// NewSlackHandler creates a webhook handler rendering Slack Block Kit messages
+func NewSlackHandler(config WebhookConfig) *WebhookHandler {
+	if config.Renderer == nil {
+		config.Renderer = SlackRenderer()
+	}
+	return NewWebhookHandler(config)
+}
+
+// NewTeamsHandler creates a webhook handler rendering Microsoft Teams adaptive cards
+func NewTeamsHandler(config WebhookConfig) *WebhookHandler {
+	if config.Renderer == nil {
+		config.Renderer = TeamsRenderer()
+	}
+	return NewWebhookHandler(config)
+}
+
+// NewDiscordHandler creates a webhook handler rendering Discord embeds.
+// MaxEntriesPerMessage is capped at Discord's limit of 10 embeds.
+func NewDiscordHandler(config WebhookConfig) *WebhookHandler {
+	if config.Renderer == nil {
+		config.Renderer = DiscordRenderer()
+	}
+	if config.MaxEntriesPerMessage <= 0 || config.MaxEntriesPerMessage > discordMaxEmbeds {
+		config.MaxEntriesPerMessage = discordMaxEmbeds
+	}
+	return NewWebhookHandler(config)
+}
+
+// Handle implements the Handler interface by adding the entry to the current group
+func (h *WebhookHandler) Handle(entry *Entry) error {
+	if entry.Level.Value < h.config.MinLevel.Value {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.pending = append(h.pending, entry.Clone())
+	h.trimPending()
+
+	// After a failed delivery, entries wait for the retry timer instead of
+	// triggering a request each
+	if len(h.pending) >= h.config.MaxEntriesPerMessage && !h.retrying {
+		if h.timer != nil {
+			h.timer.Stop()
+			h.timer = nil
+		}
+		go h.flushAsync()
+	} else if h.timer == nil {
+		h.timer = time.AfterFunc(h.config.GroupWindow, h.flushAsync)
+	}
+	return nil
+}
+
+// Flush sends the current group immediately, split into messages of at
+// most MaxEntriesPerMessage entries. If a message fails with a retryable
+// error, its entries and the rest of the group are kept for the next flush;
+// otherwise they are dropped and the returned error says how many.
+func (h *WebhookHandler) Flush() error {
+	h.sendMu.Lock()
+	defer h.sendMu.Unlock()
+
+	h.mu.Lock()
+	if h.timer != nil {
+		h.timer.Stop()
+		h.timer = nil
+	}
+	entries := h.pending
+	h.pending = nil
+	h.mu.Unlock()
+
+	for len(entries) > 0 {
+		n := len(entries)
+		if n > h.config.MaxEntriesPerMessage {
+			n = h.config.MaxEntriesPerMessage
+		}
+
+		h.mu.Lock()
+		if !h.allowSend(time.Now()) {
+			h.suppressed += len(entries)
+			h.mu.Unlock()
+			return nil
+		}
+		suppressed := h.suppressed
+		h.suppressed = 0
+		h.mu.Unlock()
+
+		err := h.send(entries[:n], suppressed)
+		if err == nil {
+			entries = entries[n:]
+			continue
+		}
+
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.suppressed += suppressed
+		if !IsRetryable(err) {
+			h.suppressed += n
+			entries = entries[n:]
+			err = fmt.Errorf("dropping %d webhook entries: %w", n, err)
+		}
+		h.pending = append(entries[:len(entries):len(entries)], h.pending...)
+		h.trimPending()
+		h.retrying = len(entries) > 0
+		if len(h.pending) > 0 && h.timer == nil {
+			h.timer = time.AfterFunc(h.config.GroupWindow, h.flushAsync)
+		}
+		return err
+	}
+
+	h.mu.Lock()
+	h.retrying = false
+	h.mu.Unlock()
+	return nil
+}
+
+// Close sends any pending entries
+func (h *WebhookHandler) Close() error {
+	return h.Flush()
+}
+
+// flushAsync flushes from a timer or goroutine and reports errors to OnError
+func (h *WebhookHandler) flushAsync() {
+	if err := h.Flush(); err != nil && h.config.OnError != nil {
+		h.config.OnError(err)
+	}
+}
+
+// send renders entries as one message and posts it. Rendering errors are
+// not retryable.
+func (h *WebhookHandler) send(entries []*Entry, suppressed int) error {
+	body, err := h.config.Renderer.Render(NewWebhookMessage(entries, suppressed))
+	if err != nil {
+		return Permanent(fmt.Errorf("rendering webhook message: %w", err))
+	}
+	return h.post(body)
+}
+
+// trimPending drops the oldest pending entries beyond MaxPending, counting
+// them as suppressed. It must be called with h.mu held.
+func (h *WebhookHandler) trimPending() {
+	if excess := len(h.pending) - h.config.MaxPending; excess > 0 {
+		h.pending = append(h.pending[:0:0], h.pending[excess:]...)
+		h.suppressed += excess
+	}
+}
+
+// allowSend reports whether the per-minute message limit permits another
+// message and records it if so. It must be called with h.mu held.
+func (h *WebhookHandler) allowSend(now time.Time) bool {
+	if h.config.MaxMessagesPerMinute <= 0 {
+		return true
+	}
+
+	cutoff := now.Add(-time.Minute)
+	recent := h.sent[:0]
+	for _, t := range h.sent {
+		if t.After(cutoff) {
+			recent = append(recent, t)
+		}
+	}
+	h.sent = recent
+
+	if len(h.sent) >= h.config.MaxMessagesPerMinute {
+		return false
+	}
+	h.sent = append(h.sent, now)
+	return true
+}
+
+// post sends body to the webhook URL
+func (h *WebhookHandler) post(body []byte) error {
+	req, err := http.NewRequest("POST", h.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-logging/1.0")
+	for k, v := range h.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := h.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("webhook request failed: %w", &HTTPStatusError{StatusCode: resp.StatusCode})
+	}
+	return nil
+}
+
+// NewWebhookMessage prepares entries for rendering
+func NewWebhookMessage(entries []*Entry, suppressed int) *WebhookMessage {
+	msg := &WebhookMessage{
+		Entries:    make([]WebhookEntry, 0, len(entries)),
+		Suppressed: suppressed,
+	}
+
+	var highest Level
+	for i, entry := range entries {
+		entry = stripMetrics(entry)
+		if i == 0 || entry.Level.Value > highest.Value {
+			highest = entry.Level
+		}
+
+		keys := make([]string, 0, len(entry.Fields))
+		for k := range entry.Fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		fields := make([]WebhookField, 0, len(keys))
+		for _, k := range keys {
+			fields = append(fields, WebhookField{Key: k, Value: fmt.Sprint(entry.Fields[k])})
+		}
+
+		msg.Entries = append(msg.Entries, WebhookEntry{
+			Level:   strings.ToUpper(entry.Level.String()),
+			Color:   LevelColor(entry.Level),
+			Message: entry.Message,
+			Time:    entry.Time,
+			Caller:  entry.Caller,
+			Fields:  fields,
+		})
+	}
+
+	msg.Level = strings.ToUpper(highest.String())
+	msg.Color = LevelColor(highest)
+	return msg
+}
+
+// LevelColor returns a hex color ("#rrggbb") for a level, used by chat renderers
+func LevelColor(level Level) string {
+	switch {
+	case level.Value >= FatalLevel.Value:
+		return "#8B0000"
+	case level.Value >= ErrorLevel.Value:
+		return "#E01E5A"
+	case level.Value >= WarnLevel.Value:
+		return "#ECB22E"
+	case level.Value >= InfoLevel.Value:
+		return "#2EB67D"
+	default:
+		return "#808080"
+	}
+}
+
+// NewTemplateRenderer creates a renderer from a text/template executed with a
+// *WebhookMessage. The template function "json" encodes a value as a JSON
+// literal, so strings can be embedded safely:
+//
+//	{"text": {{ printf "%s: %s" .Level (index .Entries 0).Message | json }}}
+func NewTemplateRenderer(text string) (WebhookRenderer, error) {
+	tmpl, err := template.New("webhook").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	return WebhookRendererFunc(func(msg *WebhookMessage) ([]byte, error) {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, msg); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}), nil
+}
+
+// slackEscaper escapes the characters Slack mrkdwn uses for mentions and
+// links, so logged text such as <!channel> is shown as it is
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// SlackRenderer renders messages as Slack Block Kit attachments colored by
+// level. Logged text is escaped, so it cannot mention users or add links.
+func SlackRenderer() WebhookRenderer {
+	return WebhookRendererFunc(func(msg *WebhookMessage) ([]byte, error) {
+		attachments := make([]map[string]interface{}, 0, len(msg.Entries))
+		for _, entry := range msg.Entries {
+			blocks := []map[string]interface{}{
+				{
+					"type": "section",
+					"text": map[string]string{
+						"type": "mrkdwn",
+						"text": fmt.Sprintf("*%s* %s", entry.Level, slackEscaper.Replace(entry.Message)),
+					},
+				},
+			}
+
+			// Slack allows at most 10 fields per section
+			for start := 0; start < len(entry.Fields); start += 10 {
+				end := start + 10
+				if end > len(entry.Fields) {
+					end = len(entry.Fields)
+				}
+				fields := make([]map[string]string, 0, end-start)
+				for _, f := range entry.Fields[start:end] {
+					fields = append(fields, map[string]string{
+						"type": "mrkdwn",
+						"text": fmt.Sprintf("*%s*\n%s", slackEscaper.Replace(f.Key), slackEscaper.Replace(f.Value)),
+					})
+				}
+				blocks = append(blocks, map[string]interface{}{
+					"type":   "section",
+					"fields": fields,
+				})
+			}
+
+			blocks = append(blocks, map[string]interface{}{
+				"type": "context",
+				"elements": []map[string]string{
+					{"type": "mrkdwn", "text": slackEscaper.Replace(webhookContext(entry))},
+				},
+			})
+
+			attachments = append(attachments, map[string]interface{}{
+				"color":  entry.Color,
+				"blocks": blocks,
+			})
+		}
+
+		return json.Marshal(map[string]interface{}{
+			"text":        slackEscaper.Replace(webhookSummary(msg)),
+			"attachments": attachments,
+		})
+	})
+}
+
+// TeamsRenderer renders messages as a Microsoft Teams adaptive card
+func TeamsRenderer() WebhookRenderer {
+	return WebhookRendererFunc(func(msg *WebhookMessage) ([]byte, error) {
+		body := []map[string]interface{}{
+			{
+				"type":   "TextBlock",
+				"text":   webhookSummary(msg),
+				"weight": "bolder",
+				"size":   "medium",
+				"color":  teamsColor(msg.Color),
+				"wrap":   true,
+			},
+		}
+
+		for _, entry := range msg.Entries {
+			body = append(body, map[string]interface{}{
+				"type":      "TextBlock",
+				"text":      fmt.Sprintf("**%s** %s", entry.Level, entry.Message),
+				"color":     teamsColor(entry.Color),
+				"wrap":      true,
+				"separator": true,
+			})
+
+			if len(entry.Fields) > 0 {
+				facts := make([]map[string]string, 0, len(entry.Fields))
+				for _, f := range entry.Fields {
+					facts = append(facts, map[string]string{"title": f.Key, "value": f.Value})
+				}
+				body = append(body, map[string]interface{}{
+					"type":  "FactSet",
+					"facts": facts,
+				})
+			}
+
+			body = append(body, map[string]interface{}{
+				"type":     "TextBlock",
+				"text":     webhookContext(entry),
+				"isSubtle": true,
+				"size":     "small",
+				"wrap":     true,
+			})
+		}
+
+		return json.Marshal(map[string]interface{}{
+			"type": "message",
+			"attachments": []map[string]interface{}{
+				{
+					"contentType": "application/vnd.microsoft.card.adaptive",
+					"content": map[string]interface{}{
+						"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+						"type":    "AdaptiveCard",
+						"version": "1.4",
+						"body":    body,
+					},
+				},
+			},
+		})
+	})
+}
+
+// Discord message limits, in characters
+const (
+	discordMaxEmbeds      = 10
+	discordMaxFields      = 25
+	discordMaxContent     = 2000
+	discordMaxTitle       = 256
+	discordMaxDescription = 4096
+	discordMaxFieldName   = 256
+	discordMaxFieldValue  = 1024
+	discordMaxFooter      = 2048
+	// discordMaxEmbedChars bounds the text of all embeds of a message
+	discordMaxEmbedChars = 6000
+)
+
+// DiscordRenderer renders messages as Discord embeds colored by level. It
+// fails for messages of more than 10 entries, which Discord rejects; use it
+// with MaxEntriesPerMessage of at most 10, as NewDiscordHandler does.
+func DiscordRenderer() WebhookRenderer {
+	return WebhookRendererFunc(func(msg *WebhookMessage) ([]byte, error) {
+		if len(msg.Entries) > discordMaxEmbeds {
+			return nil, fmt.Errorf("discord messages hold at most %d embeds, got %d entries", discordMaxEmbeds, len(msg.Entries))
+		}
+
+		// Discord rejects the whole message if any limit is exceeded, so text
+		// is truncated: each embed gets an equal share of the total, spent on
+		// the title, footer and message first and then on as many fields as fit
+		budget := discordMaxEmbedChars
+		if len(msg.Entries) > 0 {
+			budget /= len(msg.Entries)
+		}
+
+		embeds := make([]map[string]interface{}, 0, len(msg.Entries))
+		for _, entry := range msg.Entries {
+			remaining := budget
+			take := func(s string, limit int) string {
+				if limit > remaining {
+					limit = remaining
+				}
+				s = discordTruncate(s, limit)
+				remaining -= utf8.RuneCountInString(s)
+				return s
+			}
+
+			color, _ := strconv.ParseInt(strings.TrimPrefix(entry.Color, "#"), 16, 64)
+			embed := map[string]interface{}{
+				"title":     take(entry.Level, discordMaxTitle),
+				"color":     color,
+				"timestamp": entry.Time.Format(time.RFC3339),
+			}
+			if entry.Caller != "" {
+				embed["footer"] = map[string]string{"text": take(entry.Caller, discordMaxFooter)}
+			}
+			if entry.Message != "" {
+				embed["description"] = take(entry.Message, discordMaxDescription)
+			}
+
+			fields := make([]map[string]interface{}, 0, len(entry.Fields))
+			for _, f := range entry.Fields {
+				// Empty names and values are rejected
+				name := discordTruncate(discordNonEmpty(f.Key), discordMaxFieldName)
+				value := discordTruncate(discordNonEmpty(f.Value), discordMaxFieldValue)
+				size := utf8.RuneCountInString(name) + utf8.RuneCountInString(value)
+				if len(fields) == discordMaxFields || size > remaining {
+					break
+				}
+				remaining -= size
+				fields = append(fields, map[string]interface{}{
+					"name":   name,
+					"value":  value,
+					"inline": true,
+				})
+			}
+			embed["fields"] = fields
+			embeds = append(embeds, embed)
+		}
+
+		return json.Marshal(map[string]interface{}{
+			"content": discordTruncate(webhookSummary(msg), discordMaxContent),
+			"embeds":  embeds,
+		})
+	})
+}
+
+// discordTruncate shortens s to at most n characters, ending it with an
+// ellipsis when cut
+func discordTruncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	if n <= 0 {
+		return ""
+	}
+	runes := []rune(s)
+	return string(runes[:n-1]) + "…"
+}
+
+// discordNonEmpty replaces an empty string with "-"
+func discordNonEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// webhookSummary returns a one-line summary of a message
+func webhookSummary(msg *WebhookMessage) string {
+	summary := fmt.Sprintf("%s: %d log entries", msg.Level, len(msg.Entries))
+	if len(msg.Entries) == 1 {
+		summary = fmt.Sprintf("%s: %s", msg.Level, msg.Entries[0].Message)
+	}
+	if msg.Suppressed > 0 {
+		summary += fmt.Sprintf(" (%d suppressed by throttling)", msg.Suppressed)
+	}
+	return summary
+}
+
+// webhookContext returns the time and caller line of an entry
+func webhookContext(entry WebhookEntry) string {
+	context := entry.Time.Format(time.RFC3339)
+	if entry.Caller != "" {
+		context += " • " + entry.Caller
+	}
+	return context
+}
+
+// teamsColor maps a LevelColor to an adaptive card color
+func teamsColor(color string) string {
+	switch color {
+	case LevelColor(WarnLevel):
+		return "warning"
+	case LevelColor(ErrorLevel), LevelColor(FatalLevel):
+		return "attention"
+	case LevelColor(InfoLevel):
+		return "good"
+	default:
+		return "default"
+	}
+}